@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"monkey/token"
 	"strings"
 )
@@ -151,6 +152,16 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// BigIntegerLiteral is an integer literal too large for an int64.
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) expressionNode()      {}
+func (bl *BigIntegerLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BigIntegerLiteral) String() string       { return bl.Token.Literal }
+
 type PrefixExpression struct {
 	Token    token.Token // The prefix token, e.g. !
 	Operator string
@@ -247,10 +258,11 @@ func (bs *BlockStatement) String() string {
 }
 
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
-	Body       *BlockStatement
-	Name       string
+	Token       token.Token // The 'fn' token
+	Parameters  []*Identifier
+	Body        *BlockStatement
+	Name        string
+	IsGenerator bool // The body contains a yield statement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -310,6 +322,17 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// BytesLiteral is a b"..." literal. Value holds the decoded bytes while
+// Token.Literal keeps the source text between the quotes.
+type BytesLiteral struct {
+	Token token.Token
+	Value []byte
+}
+
+func (bl *BytesLiteral) expressionNode()      {}
+func (bl *BytesLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BytesLiteral) String() string       { return `b"` + bl.Token.Literal + `"` }
+
 type ArrayLiteral struct {
 	Token    token.Token // The '[' token
 	Elements []Expression
@@ -332,6 +355,29 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+type SetLiteral struct {
+	Token    token.Token // The '#{' token
+	Elements []Expression
+}
+
+func (sl *SetLiteral) expressionNode()      {}
+func (sl *SetLiteral) TokenLiteral() string { return sl.Token.Literal }
+
+func (sl *SetLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range sl.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("#{")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type IndexExpression struct {
 	Token token.Token // The '[' token
 	Left  Expression
@@ -353,9 +399,61 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is left[start:end]. Either bound may be nil, meaning the
+// start or the end of left.
+type SliceExpression struct {
+	Token token.Token // The '[' token
+	Left  Expression
+	Start Expression
+	End   Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type MemberExpression struct {
+	Token    token.Token // The '.' token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+
+func (me *MemberExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(me.Object.String())
+	out.WriteString(".")
+	out.WriteString(me.Property.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
 type HashLiteral struct {
 	Token token.Token // The '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // The keys of Pairs in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -365,8 +463,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
 
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+" "+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+" "+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -458,3 +556,98 @@ func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
 func (cs *ContinueStatement) String() string {
 	return cs.Token.Literal + ";"
 }
+
+type ForInStatement struct {
+	Token    token.Token // The 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type YieldStatement struct {
+	Token token.Token // The 'yield' token
+	Value Expression
+}
+
+func (ys *YieldStatement) statementNode()       {}
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+
+func (ys *YieldStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ys.TokenLiteral() + " ")
+
+	if ys.Value != nil {
+		out.WriteString(ys.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+type StructStatement struct {
+	Token  token.Token // The 'struct' token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+
+func (ss *StructStatement) String() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for _, f := range ss.Fields {
+		fields = append(fields, f.String())
+	}
+
+	out.WriteString(ss.TokenLiteral() + " ")
+	out.WriteString(ss.Name.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+type ImplStatement struct {
+	Token   token.Token // The 'impl' token
+	Name    *Identifier
+	Methods []*FunctionLiteral // Each method has its Name set
+}
+
+func (is *ImplStatement) statementNode()       {}
+func (is *ImplStatement) TokenLiteral() string { return is.Token.Literal }
+
+func (is *ImplStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+	out.WriteString(is.Name.String())
+	out.WriteString(" { ")
+	for _, m := range is.Methods {
+		out.WriteString(m.String())
+		out.WriteString(" ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -51,6 +51,17 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+	OpTailCall
+	OpYield
+	OpIter
+	OpIterNext
+	OpGetMember
+	OpImpl
+	OpSet
+	OpIn
+	OpSlice
+	OpStruct
+	OpSetFree
 )
 
 var definitions = map[Opcode]*Definition{
@@ -89,6 +100,17 @@ var definitions = map[Opcode]*Definition{
 	OpClosure:          {"OpClosure", []int{2, 1}},
 	OpGetFree:          {"OpGetFree", []int{1}},
 	OpCurrentClosure:   {"OpCurrentClosure", []int{}},
+	OpTailCall:         {"OpTailCall", []int{1}},
+	OpYield:            {"OpYield", []int{}},
+	OpIter:             {"OpIter", []int{}},
+	OpIterNext:         {"OpIterNext", []int{2}},
+	OpGetMember:        {"OpGetMember", []int{2}},
+	OpImpl:             {"OpImpl", []int{1}},
+	OpSet:              {"OpSet", []int{2}},
+	OpIn:               {"OpIn", []int{}},
+	OpSlice:            {"OpSlice", []int{}},
+	OpStruct:           {"OpStruct", []int{2}},
+	OpSetFree:          {"OpSetFree", []int{1}},
 }
 
 func Lookup(op byte) (*Definition, error) {
@@ -5,7 +5,6 @@ import (
 	"monkey/ast"
 	"monkey/code"
 	"monkey/object"
-	"sort"
 )
 
 type EmittedInstruction struct {
@@ -39,6 +38,9 @@ func New() *Compiler {
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
+	for i, v := range object.BuiltinValues {
+		symbolTable.DefineBuiltinValue(i, v.Name)
+	}
 
 	return &Compiler{
 		constants:   []object.Object{},
@@ -147,6 +149,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpGreaterThan)
 		case ">=":
 			c.emit(code.OpGreaterThanEqual)
+		case "in":
+			c.emit(code.OpIn)
 		default:
 			return fmt.Errorf("unknown operator %s", node.Operator)
 		}
@@ -172,6 +176,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.BigIntegerLiteral:
+		integer := &object.BigInt{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
 	case *ast.FloatLiteral:
 		float := &object.Float{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(float))
@@ -182,13 +189,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
-		err = c.Compile(node.Consequence)
+		err = c.compileBlock(node.Consequence)
 		if err != nil {
 			return err
 		}
-		if c.lastInstructionIsPop() {
-			c.removeLastPop()
-		}
+		c.leaveBlockValue()
 
 		// Emit an `OpJump` with a bogus value
 		jumpPos := c.emit(code.OpJump, 9999)
@@ -198,14 +203,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.Alternative == nil {
 			c.emit(code.OpNull)
 		} else {
-			err := c.Compile(node.Alternative)
+			err := c.compileBlock(node.Alternative)
 			if err != nil {
 				return err
 			}
-
-			if c.lastInstructionIsPop() {
-				c.removeLastPop()
-			}
+			c.leaveBlockValue()
 		}
 
 		afterAlternativePos := len(c.currentInstructions())
@@ -238,6 +240,9 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.BytesLiteral:
+		data := &object.Bytes{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(data))
 	case *ast.ArrayLiteral:
 		for _, el := range node.Elements {
 			err := c.Compile(el)
@@ -246,15 +251,17 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 		c.emit(code.OpArray, len(node.Elements))
+	case *ast.SetLiteral:
+		for _, el := range node.Elements {
+			err := c.Compile(el)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSet, len(node.Elements))
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-		for _, k := range keys {
+		// Keys are compiled in source order, which is the order of the hash
+		for _, k := range node.Keys {
 			v := node.Pairs[k]
 			err := c.Compile(k)
 			if err != nil {
@@ -276,6 +283,53 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 		c.emit(code.OpIndex)
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+			err := c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+	case *ast.MemberExpression:
+		err := c.Compile(node.Object)
+		if err != nil {
+			return err
+		}
+		name := &object.String{Value: node.Property.Value}
+		c.emit(code.OpGetMember, c.addConstant(name))
+	case *ast.StructStatement:
+		if object.IsBuiltinType(node.Name.Value) {
+			return fmt.Errorf("struct name %s is reserved for a built-in type", node.Name.Value)
+		}
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		// Like a function literal, the constant is only a template: every
+		// execution of the statement creates a distinct struct type.
+		structType := object.NewStructType(node.Name.Value, fields)
+		c.emit(code.OpStruct, c.addConstant(structType))
+
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if symbol.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+	case *ast.ImplStatement:
+		err := c.compileImplStatement(node)
+		if err != nil {
+			return err
+		}
 	case *ast.FunctionLiteral:
 		c.enterScope()
 		if node.Name != "" {
@@ -295,9 +349,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if !c.lastInstructionIs(code.OpReturnValue) {
 			c.emit(code.OpReturn)
 		}
+		c.markTailCalls()
 
 		freeSymbols := c.symbolTable.FreeSymbols
-		numLocals := c.symbolTable.numDefinitions
+		numLocals := c.symbolTable.NumLocals()
 		instructions := c.leaveScope()
 
 		for _, s := range freeSymbols {
@@ -307,6 +362,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			IsGenerator:   node.IsGenerator,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
@@ -316,6 +372,12 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 		c.emit(code.OpReturnValue)
+	case *ast.YieldStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpYield)
 	case *ast.CallExpression:
 		err := c.Compile(node.Function)
 		if err != nil {
@@ -359,10 +421,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return fmt.Errorf("unknown assignment operator %s", node.Operator)
 		}
 
-		// Store the result back to the variable
-		if symbol.Scope == GlobalScope {
+		// Store the result back to the variable. A free variable is the
+		// closure's own copy of the captured value.
+		switch symbol.Scope {
+		case GlobalScope:
 			c.emit(code.OpSetGlobal, symbol.Index)
-		} else {
+		case FreeScope:
+			c.emit(code.OpSetFree, symbol.Index)
+		default:
 			c.emit(code.OpSetLocal, symbol.Index)
 		}
 
@@ -373,6 +439,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 		return c.compileForStatement(node)
 	case *ast.WhileStatement:
 		return c.compileWhileStatement(node)
+	case *ast.ForInStatement:
+		return c.compileForInStatement(node)
 	case *ast.BreakStatement:
 		return fmt.Errorf("break statement not yet supported in compiler")
 	case *ast.ContinueStatement:
@@ -384,12 +452,14 @@ func (c *Compiler) Compile(node ast.Node) error {
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	NumLocals    int // local slots used by blocks at the top level
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		NumLocals:    c.symbolTable.NumLocals(),
 	}
 }
 
@@ -428,6 +498,17 @@ func (c *Compiler) lastInstructionIsPop() bool {
 	return c.scopes[c.scopeIndex].lastInstruction.Opcode == code.OpPop
 }
 
+// leaveBlockValue keeps the value of the last expression of a block on the
+// stack, or pushes null when the block does not end with an expression
+// (e.g. it is empty or ends with a let statement).
+func (c *Compiler) leaveBlockValue() {
+	if c.lastInstructionIsPop() {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
 func (c *Compiler) removeLastPop() {
 	last := c.scopes[c.scopeIndex].lastInstruction
 	previous := c.scopes[c.scopeIndex].previousInstruction
@@ -471,12 +552,67 @@ func (c *Compiler) leaveScope() code.Instructions {
 	return instructions
 }
 
+// compileBlock compiles the body of an if or loop in its own block scope so
+// that names defined by `let` are not visible after the block.
+func (c *Compiler) compileBlock(block *ast.BlockStatement) error {
+	c.enterBlockScope()
+	defer c.leaveBlockScope()
+	return c.Compile(block)
+}
+
+func (c *Compiler) enterBlockScope() {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlockScope() {
+	c.symbolTable.Release()
+	c.symbolTable = c.symbolTable.Outer
+}
+
 func (c *Compiler) replaceLastPopWithReturn() {
 	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
 	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
 	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
 }
 
+// markTailCalls turns every OpCall of the current scope whose result is
+// returned right away into an OpTailCall, so the VM can reuse the frame.
+func (c *Compiler) markTailCalls() {
+	ins := c.currentInstructions()
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return
+		}
+		_, read := code.ReadOperands(def, ins[i+1:])
+		next := i + 1 + read
+		if code.Opcode(ins[i]) == code.OpCall && returnsImmediately(ins, next) {
+			ins[i] = byte(code.OpTailCall)
+		}
+		i = next
+	}
+}
+
+// returnsImmediately reports whether execution starting at pos reaches an
+// OpReturnValue through nothing but forward jumps.
+func returnsImmediately(ins code.Instructions, pos int) bool {
+	for pos < len(ins) {
+		switch code.Opcode(ins[pos]) {
+		case code.OpReturnValue:
+			return true
+		case code.OpJump:
+			target := int(code.ReadUint16(ins[pos+1:]))
+			if target <= pos {
+				return false
+			}
+			pos = target
+		default:
+			return false
+		}
+	}
+	return false
+}
+
 func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
 	if len(c.currentInstructions()) == 0 {
 		return false
@@ -496,10 +632,16 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpGetFree, s.Index)
 	case FunctionScope:
 		c.emit(code.OpCurrentClosure)
+	case BuiltinValueScope:
+		c.emit(code.OpConstant, c.addConstant(object.BuiltinValues[s.Index].Value))
 	}
 }
 
 func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	// The loop variables live in a block of their own around the body
+	c.enterBlockScope()
+	defer c.leaveBlockScope()
+
 	// Compile initialization
 	if node.Init != nil {
 		err := c.Compile(node.Init)
@@ -523,7 +665,7 @@ func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
 	}
 
 	// Compile body
-	err := c.Compile(node.Body)
+	err := c.compileBlock(node.Body)
 	if err != nil {
 		return err
 	}
@@ -562,7 +704,7 @@ func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
 	conditionJump := c.emit(code.OpJumpNotTruthy, 9999) // placeholder
 
 	// Compile body
-	err = c.Compile(node.Body)
+	err = c.compileBlock(node.Body)
 	if err != nil {
 		return err
 	}
@@ -576,3 +718,63 @@ func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
 
 	return nil
 }
+
+func (c *Compiler) compileImplStatement(node *ast.ImplStatement) error {
+	err := c.Compile(node.Name)
+	if err != nil {
+		return err
+	}
+
+	for _, method := range node.Methods {
+		name := &object.String{Value: method.Name}
+		c.emit(code.OpConstant, c.addConstant(name))
+
+		// Methods are reached through the receiver, not by name from
+		// inside their own bodies
+		fn := *method
+		fn.Name = ""
+		err := c.Compile(&fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpImpl, len(node.Methods))
+	return nil
+}
+
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	c.enterBlockScope()
+	defer c.leaveBlockScope()
+
+	// The iterator stays on the stack for the duration of the loop
+	err := c.Compile(node.Iterable)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	loopStart := len(c.currentInstructions())
+
+	// Push the next value, or pop the iterator and leave the loop
+	exitJump := c.emit(code.OpIterNext, 9999) // placeholder
+	symbol := c.symbolTable.Define(node.Variable.Value)
+	c.emit(code.OpSetLocal, symbol.Index)
+
+	err = c.compileBlock(node.Body)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, loopStart)
+
+	afterLoopPos := len(c.currentInstructions())
+	c.changeOperand(exitJump, afterLoopPos)
+
+	// Like in the evaluator, a finished loop leaves null rather than the
+	// iterator as the last popped value
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
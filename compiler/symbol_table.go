@@ -8,6 +8,9 @@ const (
 	BuiltinScope  SymbolScope = "BUILTIN"
 	FreeScope     SymbolScope = "FREE"
 	FunctionScope SymbolScope = "FUNCTION"
+	// BuiltinValueScope holds the predefined values such as PI, indexed
+	// into object.BuiltinValues
+	BuiltinValueScope SymbolScope = "BUILTIN_VALUE"
 )
 
 type Symbol struct {
@@ -21,12 +24,22 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 	FreeSymbols    []Symbol
+
+	// frame is the table (global or function) whose frame holds the local
+	// slots of this table. Block tables share the slots of their frame.
+	frame      *SymbolTable
+	isBlock    bool
+	numLocals  int // next free local slot, only meaningful on frame tables
+	maxLocals  int // high-water mark of numLocals
+	localsBase int // numLocals of the frame when the block was entered
 }
 
 func NewSymbolTable() *SymbolTable {
 	s := make(map[string]Symbol)
 	free := []Symbol{}
-	return &SymbolTable{store: s, FreeSymbols: free}
+	table := &SymbolTable{store: s, FreeSymbols: free}
+	table.frame = table
+	return table
 }
 
 func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
@@ -35,18 +48,54 @@ func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
 	return s
 }
 
+// NewBlockSymbolTable creates a table for a block (if/loop body) nested in
+// outer. Names defined in it are locals of the enclosing frame whose slots
+// are handed back by Release when the block is left.
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	s.frame = outer.frame
+	s.isBlock = true
+	s.localsBase = outer.frame.numLocals
+	return s
+}
+
 func (s *SymbolTable) Define(name string) Symbol {
-	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	symbol := Symbol{Name: name}
 	if s.Outer == nil {
 		symbol.Scope = GlobalScope
+		symbol.Index = s.numDefinitions
+		s.numDefinitions++
 	} else {
 		symbol.Scope = LocalScope
+		symbol.Index = s.frame.allocateLocal()
 	}
 	s.store[name] = symbol
-	s.numDefinitions++
 	return symbol
 }
 
+func (s *SymbolTable) allocateLocal() int {
+	index := s.numLocals
+	s.numLocals++
+	if s.numLocals > s.maxLocals {
+		s.maxLocals = s.numLocals
+	}
+	return index
+}
+
+// Release frees the local slots defined in a block table so that later
+// definitions in the same frame can reuse them.
+func (s *SymbolTable) Release() {
+	if s.isBlock {
+		s.frame.numLocals = s.localsBase
+	}
+}
+
+// NumLocals returns the number of local slots the frame of s needs.
+func (s *SymbolTable) NumLocals() int {
+	return s.frame.maxLocals
+}
+
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
 	if !ok && s.Outer != nil {
@@ -54,7 +103,7 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 		if !ok {
 			return obj, false
 		}
-		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		if s.isBlock || obj.Scope == GlobalScope || obj.Scope == BuiltinScope || obj.Scope == BuiltinValueScope {
 			return obj, ok
 		}
 		free := s.DefineFree(obj)
@@ -69,6 +118,12 @@ func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	return symbol
 }
 
+func (s *SymbolTable) DefineBuiltinValue(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinValueScope}
+	s.store[name] = symbol
+	return symbol
+}
+
 func (s *SymbolTable) DefineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
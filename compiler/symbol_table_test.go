@@ -312,3 +312,62 @@ func TestShadowingFunctionName(t *testing.T) {
 		t.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
 	}
 }
+
+func TestDefineAndResolveBlockScopes(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	block := NewBlockSymbolTable(global)
+	expected := []Symbol{
+		{Name: "b", Scope: LocalScope, Index: 0},
+		{Name: "c", Scope: LocalScope, Index: 1},
+	}
+	for _, sym := range expected {
+		if result := block.Define(sym.Name); result != sym {
+			t.Errorf("expected %s=%+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	result, ok := block.Resolve("a")
+	if !ok || result != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("expected a to resolve to global, got=%+v (%t)", result, ok)
+	}
+
+	block.Release()
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("name b resolved outside of its block")
+	}
+	if global.NumLocals() != 2 {
+		t.Errorf("wrong number of locals. got=%d, want=2", global.NumLocals())
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("d")
+	inner := NewBlockSymbolTable(local)
+	e := inner.Define("e")
+	if e != (Symbol{Name: "e", Scope: LocalScope, Index: 1}) {
+		t.Errorf("expected block local e in slot 1, got=%+v", e)
+	}
+	d, ok := inner.Resolve("d")
+	if !ok || d != (Symbol{Name: "d", Scope: LocalScope, Index: 0}) {
+		t.Errorf("expected d to resolve to enclosing local, got=%+v (%t)", d, ok)
+	}
+	if len(local.FreeSymbols) != 0 || len(inner.FreeSymbols) != 0 {
+		t.Errorf("block lookups must not create free symbols")
+	}
+	inner.Release()
+
+	reused := local.Define("f")
+	if reused.Index != 1 {
+		t.Errorf("expected released slot 1 to be reused, got=%d", reused.Index)
+	}
+	if local.NumLocals() != 2 {
+		t.Errorf("wrong number of locals. got=%d, want=2", local.NumLocals())
+	}
+
+	nested := NewEnclosedSymbolTable(NewBlockSymbolTable(local))
+	free, ok := nested.Resolve("d")
+	if !ok || free.Scope != FreeScope {
+		t.Errorf("expected d to be free in nested function, got=%+v (%t)", free, ok)
+	}
+}
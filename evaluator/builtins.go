@@ -10,4 +10,160 @@ var builtins = map[string]*object.Builtin{
 	"rest":  object.GetBuiltinByName("rest"),
 	"push":  object.GetBuiltinByName("push"),
 	"pop":   object.GetBuiltinByName("pop"),
+	"next":  object.GetBuiltinByName("next"),
+
+	"set":          object.GetBuiltinByName("set"),
+	"union":        object.GetBuiltinByName("union"),
+	"intersection": object.GetBuiltinByName("intersection"),
+	"difference":   object.GetBuiltinByName("difference"),
+
+	"encode":        object.GetBuiltinByName("encode"),
+	"decode":        object.GetBuiltinByName("decode"),
+	"base64_encode": object.GetBuiltinByName("base64_encode"),
+	"base64_decode": object.GetBuiltinByName("base64_decode"),
+	"hex_encode":    object.GetBuiltinByName("hex_encode"),
+	"hex_decode":    object.GetBuiltinByName("hex_decode"),
+	"url_encode":    object.GetBuiltinByName("url_encode"),
+	"url_decode":    object.GetBuiltinByName("url_decode"),
+
+	"now":         object.GetBuiltinByName("now"),
+	"time":        object.GetBuiltinByName("time"),
+	"parse_time":  object.GetBuiltinByName("parse_time"),
+	"format_time": object.GetBuiltinByName("format_time"),
+	"duration":    object.GetBuiltinByName("duration"),
+	"in_zone":     object.GetBuiltinByName("in_zone"),
+
+	"upper":         object.GetBuiltinByName("upper"),
+	"lower":         object.GetBuiltinByName("lower"),
+	"split":         object.GetBuiltinByName("split"),
+	"join":          object.GetBuiltinByName("join"),
+	"trim":          object.GetBuiltinByName("trim"),
+	"trim_left":     object.GetBuiltinByName("trim_left"),
+	"trim_right":    object.GetBuiltinByName("trim_right"),
+	"contains":      object.GetBuiltinByName("contains"),
+	"starts_with":   object.GetBuiltinByName("starts_with"),
+	"ends_with":     object.GetBuiltinByName("ends_with"),
+	"index_of":      object.GetBuiltinByName("index_of"),
+	"last_index_of": object.GetBuiltinByName("last_index_of"),
+	"repeat":        object.GetBuiltinByName("repeat"),
+	"pad_left":      object.GetBuiltinByName("pad_left"),
+	"pad_right":     object.GetBuiltinByName("pad_right"),
+	"replace_all":   object.GetBuiltinByName("replace_all"),
+	"ord":           object.GetBuiltinByName("ord"),
+	"chr":           object.GetBuiltinByName("chr"),
+	"format":        object.GetBuiltinByName("format"),
+
+	"chars":     object.GetBuiltinByName("chars"),
+	"runes":     object.GetBuiltinByName("runes"),
+	"normalize": object.GetBuiltinByName("normalize"),
+
+	"regex":           object.GetBuiltinByName("regex"),
+	"match":           object.GetBuiltinByName("match"),
+	"replace":         object.GetBuiltinByName("replace"),
+	"regex_split":     object.GetBuiltinByName("regex_split"),
+	"match_all":       object.GetBuiltinByName("match_all"),
+	"named_groups":    object.GetBuiltinByName("named_groups"),
+	"match_positions": object.GetBuiltinByName("match_positions"),
+
+	"map":      object.GetBuiltinByName("map"),
+	"filter":   object.GetBuiltinByName("filter"),
+	"reduce":   object.GetBuiltinByName("reduce"),
+	"each":     object.GetBuiltinByName("each"),
+	"find":     object.GetBuiltinByName("find"),
+	"any":      object.GetBuiltinByName("any"),
+	"all":      object.GetBuiltinByName("all"),
+	"flat_map": object.GetBuiltinByName("flat_map"),
+	"zip":      object.GetBuiltinByName("zip"),
+
+	"sort":    object.GetBuiltinByName("sort"),
+	"sort_by": object.GetBuiltinByName("sort_by"),
+	"reverse": object.GetBuiltinByName("reverse"),
+
+	"read_file":   object.GetBuiltinByName("read_file"),
+	"write_file":  object.GetBuiltinByName("write_file"),
+	"append_file": object.GetBuiltinByName("append_file"),
+	"list_dir":    object.GetBuiltinByName("list_dir"),
+	"exists":      object.GetBuiltinByName("exists"),
+	"mkdir":       object.GetBuiltinByName("mkdir"),
+	"remove":      object.GetBuiltinByName("remove"),
+	"file_lines":  object.GetBuiltinByName("file_lines"),
+
+	"abs":        object.GetBuiltinByName("abs"),
+	"min":        object.GetBuiltinByName("min"),
+	"max":        object.GetBuiltinByName("max"),
+	"sqrt":       object.GetBuiltinByName("sqrt"),
+	"sum":        object.GetBuiltinByName("sum"),
+	"clamp":      object.GetBuiltinByName("clamp"),
+	"pow":        object.GetBuiltinByName("pow"),
+	"floor":      object.GetBuiltinByName("floor"),
+	"ceil":       object.GetBuiltinByName("ceil"),
+	"trunc":      object.GetBuiltinByName("trunc"),
+	"round":      object.GetBuiltinByName("round"),
+	"log":        object.GetBuiltinByName("log"),
+	"log2":       object.GetBuiltinByName("log2"),
+	"log10":      object.GetBuiltinByName("log10"),
+	"exp":        object.GetBuiltinByName("exp"),
+	"sin":        object.GetBuiltinByName("sin"),
+	"cos":        object.GetBuiltinByName("cos"),
+	"tan":        object.GetBuiltinByName("tan"),
+	"asin":       object.GetBuiltinByName("asin"),
+	"acos":       object.GetBuiltinByName("acos"),
+	"atan":       object.GetBuiltinByName("atan"),
+	"atan2":      object.GetBuiltinByName("atan2"),
+	"random":     object.GetBuiltinByName("random"),
+	"random_int": object.GetBuiltinByName("random_int"),
+	"shuffle":    object.GetBuiltinByName("shuffle"),
+	"choice":     object.GetBuiltinByName("choice"),
+	"seed":       object.GetBuiltinByName("seed"),
+
+	"keys":         object.GetBuiltinByName("keys"),
+	"values":       object.GetBuiltinByName("values"),
+	"entries":      object.GetBuiltinByName("entries"),
+	"has":          object.GetBuiltinByName("has"),
+	"delete":       object.GetBuiltinByName("delete"),
+	"merge":        object.GetBuiltinByName("merge"),
+	"deep_merge":   object.GetBuiltinByName("deep_merge"),
+	"from_entries": object.GetBuiltinByName("from_entries"),
+	"get":          object.GetBuiltinByName("get"),
+
+	"type":        object.GetBuiltinByName("type"),
+	"is_int":      object.GetBuiltinByName("is_int"),
+	"is_float":    object.GetBuiltinByName("is_float"),
+	"is_number":   object.GetBuiltinByName("is_number"),
+	"is_string":   object.GetBuiltinByName("is_string"),
+	"is_bool":     object.GetBuiltinByName("is_bool"),
+	"is_null":     object.GetBuiltinByName("is_null"),
+	"is_array":    object.GetBuiltinByName("is_array"),
+	"is_hash":     object.GetBuiltinByName("is_hash"),
+	"is_set":      object.GetBuiltinByName("is_set"),
+	"is_bytes":    object.GetBuiltinByName("is_bytes"),
+	"is_function": object.GetBuiltinByName("is_function"),
+	"int":         object.GetBuiltinByName("int"),
+	"float":       object.GetBuiltinByName("float"),
+	"str":         object.GetBuiltinByName("str"),
+	"bool":        object.GetBuiltinByName("bool"),
+	"repr":        object.GetBuiltinByName("repr"),
+
+	"read_line": object.GetBuiltinByName("read_line"),
+	"read_all":  object.GetBuiltinByName("read_all"),
+	"lines":     object.GetBuiltinByName("lines"),
+
+	"args":    object.GetBuiltinByName("args"),
+	"env":     object.GetBuiltinByName("env"),
+	"env_all": object.GetBuiltinByName("env_all"),
+	"exit":    object.GetBuiltinByName("exit"),
+	"eputs":   object.GetBuiltinByName("eputs"),
+
+	"json_parse":     object.GetBuiltinByName("json_parse"),
+	"json_stringify": object.GetBuiltinByName("json_stringify"),
+	"csv_parse":      object.GetBuiltinByName("csv_parse"),
+	"csv_stringify":  object.GetBuiltinByName("csv_stringify"),
 }
+
+var builtinValues = func() map[string]object.Object {
+	values := map[string]object.Object{}
+	for _, v := range object.BuiltinValues {
+		values[v.Name] = v.Value
+	}
+	return values
+}()
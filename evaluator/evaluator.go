@@ -16,6 +16,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return object.NewInteger(node.Value)
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
@@ -58,7 +60,14 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		closureEnv := env.Capture()
+		fn := &object.Function{Parameters: params, Body: body, Env: closureEnv, IsGenerator: node.IsGenerator}
+		if closureEnv != env && node.Name != "" {
+			// The copy is taken before the let binds the function, so a
+			// local function finds itself under its own name
+			closureEnv.Set(node.Name, fn)
+		}
+		return fn
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isError(function) {
@@ -69,9 +78,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return applyFunction(function, args, env)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.BytesLiteral:
+		return &object.Bytes{Value: node.Value}
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -88,8 +99,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.MemberExpression:
+		obj := Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Property.Value)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.SetLiteral:
+		return evalSetLiteral(node, env)
 	case *ast.FloatLiteral:
 		return &object.Float{Value: node.Value}
 	case *ast.AssignmentExpression:
@@ -98,6 +119,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalForStatement(node, env)
 	case *ast.WhileStatement:
 		return evalWhileStatement(node, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, env)
+	case *ast.YieldStatement:
+		return evalYieldStatement(node, env)
+	case *ast.StructStatement:
+		if object.IsBuiltinType(node.Name.Value) {
+			return newError("struct name %s is reserved for a built-in type", node.Name.Value)
+		}
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		env.Set(node.Name.Value, object.NewStructType(node.Name.Value, fields))
+	case *ast.ImplStatement:
+		return evalImplStatement(node, env)
 	case *ast.BreakStatement:
 		return &object.Break{}
 	case *ast.ContinueStatement:
@@ -121,7 +157,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError("unknown operator: %s%s", operator, object.TypeName(right))
 	}
 }
 
@@ -139,69 +175,59 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() == object.INTEGER_OBJ {
-		value := right.(*object.Integer).Value
-		return object.NewInteger(-value)
+	if object.IsInteger(right) {
+		return object.NegateInteger(right)
 	} else if right.Type() == object.FLOAT_OBJ {
 		value := right.(*object.Float).Value
 		return &object.Float{Value: -value}
 	} else {
-		return newError("unknown operator: -%s", right.Type())
+		return newError("unknown operator: -%s", object.TypeName(right))
 	}
 }
 
 func evalInflixExpression(operator string, left, right object.Object) object.Object {
+	if result, ok := object.Compare(operator, left, right); ok {
+		return nativeBoolToBooleanObject(result)
+	}
+
 	switch {
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+	case operator == "in":
+		return evalInExpression(left, right)
+	case object.IsInteger(left) && object.IsInteger(right):
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
 		return evalFloatInfixExpression(operator, left, right)
-	case left.Type() == object.FLOAT_OBJ && right.Type() == object.INTEGER_OBJ:
-		convertedRight := &object.Float{Value: float64(right.(*object.Integer).Value)}
+	case left.Type() == object.FLOAT_OBJ && object.IsInteger(right):
+		convertedRight := &object.Float{Value: object.IntegerToFloat(right)}
 		return evalFloatInfixExpression(operator, left, convertedRight)
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.FLOAT_OBJ:
-		convertedLeft := &object.Float{Value: float64(left.(*object.Integer).Value)}
+	case object.IsInteger(left) && right.Type() == object.FLOAT_OBJ:
+		convertedLeft := &object.Float{Value: object.IntegerToFloat(left)}
 		return evalFloatInfixExpression(operator, convertedLeft, right)
-	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+	case object.IsTimeValue(left) || object.IsTimeValue(right):
+		result, err := object.TimeArithmetic(operator, left, right)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError("type mismatch: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
-
 	switch operator {
-	case "+":
-		return object.NewInteger(leftVal + rightVal)
-	case "-":
-		return object.NewInteger(leftVal - rightVal)
-	case "*":
-		return object.NewInteger(leftVal * rightVal)
-	case "/":
-		return object.NewInteger(leftVal / rightVal)
-	case "<":
-		return nativeBoolToBooleanObject(leftVal < rightVal)
-	case ">":
-		return nativeBoolToBooleanObject(leftVal > rightVal)
-	case "<=":
-		return nativeBoolToBooleanObject(leftVal <= rightVal)
-	case ">=":
-		return nativeBoolToBooleanObject(leftVal >= rightVal)
-	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
-	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "+", "-", "*", "/":
+		result, err := object.IntegerArithmetic(operator, left, right)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	}
 }
 
@@ -218,20 +244,8 @@ func evalFloatInfixExpression(operator string, left, right object.Object) object
 		return &object.Float{Value: leftVal * rightVal}
 	case "/":
 		return &object.Float{Value: leftVal / rightVal}
-	case "<":
-		return nativeBoolToBooleanObject(leftVal < rightVal)
-	case ">":
-		return nativeBoolToBooleanObject(leftVal > rightVal)
-	case "<=":
-		return nativeBoolToBooleanObject(leftVal <= rightVal)
-	case ">=":
-		return nativeBoolToBooleanObject(leftVal >= rightVal)
-	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
-	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	}
 }
 
@@ -241,9 +255,9 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return Eval(ie.Consequence, object.NewEnclosedEnvironment(env))
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return Eval(ie.Alternative, object.NewEnclosedEnvironment(env))
 	} else {
 		return object.NULL
 	}
@@ -317,6 +331,10 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
+	if value, ok := builtinValues[node.Value]; ok {
+		return value
+	}
+
 	return newError("identifier not found: " + node.Value)
 }
 
@@ -334,22 +352,48 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with args. env is the environment of the call,
+// through which builtins reach the host.
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+		if fn.IsGenerator {
+			return newGenerator(fn, args)
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
+		if result := fn.Apply(caller{env: env}, args...); result != nil {
 			return result
 		}
 		return object.NULL
+	case *object.StructType:
+		return fn.Construct(args)
+	case *object.BoundMethod:
+		return applyFunction(fn.Method, append([]object.Object{fn.Receiver}, args...), env)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError("not a function: %s", object.TypeName(fn))
 	}
 }
 
+// caller lets builtins call back into functions through applyFunction and
+// gives them the host of the environment they were called from.
+type caller struct {
+	env *object.Environment
+}
+
+func (c caller) Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(fn, args, c.env)
+}
+
+func (c caller) Host() *object.Host {
+	return c.env.Host()
+}
+
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
@@ -370,7 +414,7 @@ func unwrapReturnValue(obj object.Object) object.Object {
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError("unknown operator: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	}
 
 	leftVal := left.(*object.String).Value
@@ -382,10 +426,17 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return object.StringIndex(left.(*object.String).Value, index.(*object.Integer).Value)
+	case left.Type() == object.BYTES_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalBytesIndexExpression(left, index)
+	case index.Type() == object.BIGINT_OBJ && object.IsSequence(left):
+		// A big integer lies outside every array, string and bytes value
+		return object.NULL
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError("index operator not supported: %s", object.TypeName(left))
 	}
 }
 
@@ -401,18 +452,54 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+func evalBytesIndexExpression(data, index object.Object) object.Object {
+	value := data.(*object.Bytes).Value
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 || idx >= int64(len(value)) {
+		return object.NULL
+	}
+
+	return object.NewInteger(int64(value[idx]))
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := []object.Object{object.NULL, object.NULL}
+	for i, bound := range []ast.Expression{node.Start, node.End} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	result, err := object.Slice(left, bounds[0], bounds[1])
+	if err != nil {
+		return newError("%s", err)
+	}
+	return result
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.Keys {
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashed, ok := object.HashKeyOf(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError("unusable as hash key: %s", object.TypeName(key))
 		}
 
 		value := Eval(valueNode, env)
@@ -420,22 +507,46 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashed, object.HashPair{Key: key, Value: value})
+	}
+
+	return hash
+}
+
+func evalSetLiteral(node *ast.SetLiteral, env *object.Environment) object.Object {
+	elements := evalExpressions(node.Elements, env)
+	if len(elements) == 1 && isError(elements[0]) {
+		return elements[0]
+	}
+
+	set := object.NewSet()
+	for _, el := range elements {
+		if !set.Add(el) {
+			return newError("unusable as set element: %s", object.TypeName(el))
+		}
+	}
+
+	return set
+}
+
+func evalInExpression(item, container object.Object) object.Object {
+	found, ok := object.Contains(container, item)
+	if !ok {
+		return newError("unknown operator: %s in %s", object.TypeName(item), object.TypeName(container))
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return nativeBoolToBooleanObject(found)
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashKeyOf(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError("unusable as hash key: %s", object.TypeName(index))
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return object.NULL
 	}
@@ -497,8 +608,8 @@ func evalAssignmentExpression(node *ast.AssignmentExpression, env *object.Enviro
 		return newVal
 	}
 
-	// Set the new value in the environment
-	env.Set(node.Name.Value, newVal)
+	// Update the binding where it was defined
+	env.Assign(node.Name.Value, newVal)
 
 	// Return the new value
 	return newVal
@@ -530,8 +641,8 @@ func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Ob
 			}
 		}
 
-		// Execute body
-		result = evalBlockStatement(node.Body, loopEnv)
+		// Execute body in a fresh block scope per iteration
+		result = evalBlockStatement(node.Body, object.NewEnclosedEnvironment(loopEnv))
 
 		// Handle break and continue
 		if result != nil {
@@ -575,8 +686,8 @@ func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) objec
 			break
 		}
 
-		// Execute body
-		result = evalBlockStatement(node.Body, loopEnv)
+		// Execute body in a fresh block scope per iteration
+		result = evalBlockStatement(node.Body, object.NewEnclosedEnvironment(loopEnv))
 
 		// Handle break and continue
 		if result != nil {
@@ -595,3 +706,95 @@ func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) objec
 
 	return result
 }
+
+func evalForInStatement(node *ast.ForInStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iterator, ok := object.Iterate(iterable)
+	if !ok {
+		return newError("cannot iterate over %s", object.TypeName(iterable))
+	}
+
+	for {
+		value, ok := iterator.Next()
+		if !ok {
+			break
+		}
+		if isError(value) {
+			return value
+		}
+
+		// Each iteration binds the loop variable in a fresh block scope
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(node.Variable.Value, value)
+		result := evalBlockStatement(node.Body, loopEnv)
+
+		// Handle break and continue
+		if result != nil {
+			if result.Type() == object.BREAK_OBJ {
+				break
+			}
+			if result.Type() == object.CONTINUE_OBJ {
+				continue
+			} else if result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	// The loop is a statement, which leaves null in both engines
+	return object.NULL
+}
+
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	accessor, ok := obj.(object.Member)
+	if !ok {
+		return newError("member access not supported: %s", object.TypeName(obj))
+	}
+
+	member, ok := accessor.Member(name)
+	if !ok {
+		return newError("unknown member %s on %s", name, object.ValueType(obj))
+	}
+
+	return member
+}
+
+func evalImplStatement(node *ast.ImplStatement, env *object.Environment) object.Object {
+	target := evalIdentifier(node.Name, env)
+	if isError(target) {
+		return target
+	}
+
+	structType, ok := target.(*object.StructType)
+	if !ok {
+		return newError("cannot impl %s: not a struct", object.TypeName(target))
+	}
+
+	for _, method := range node.Methods {
+		fn := &object.Function{Parameters: method.Parameters, Body: method.Body, Env: env, IsGenerator: method.IsGenerator}
+		if !structType.DefineMethod(method.Name, fn) {
+			return newError("method %s conflicts with a field of %s", method.Name, structType.Name)
+		}
+	}
+
+	return nil
+}
+
+func evalYieldStatement(node *ast.YieldStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	yield, ok := env.Yield()
+	if !ok {
+		return newError("yield outside of generator")
+	}
+	yield(val)
+
+	return nil
+}
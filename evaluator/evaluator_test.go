@@ -4,7 +4,9 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -534,12 +536,12 @@ func TestHashLiterals(t *testing.T) {
 		object.FALSE.HashKey():                     6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong number of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong number of pairs. got=%d", result.Len())
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -608,3 +610,100 @@ func TestLoopStatements(t *testing.T) {
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let g = fn() { yield 1; yield 2; }(); next(g) + next(g)`, 3},
+		{`let g = fn() { yield 1; }(); next(g); next(g)`, nil},
+		{`let sum = 0; for (x in fn() { yield 4; yield 5; }()) { sum += x; } sum`, 9},
+		{`let nat = fn() { let i = 0; while (true) { yield i; i += 1; } }; first(rest(nat()))`, 1},
+		{`let g = fn() { yield 1; yield -true; }(); next(g); next(g)`, "unknown operator: -BOOLEAN"},
+		{`let sum = 0; for (x in fn() { yield 1; yield -true; }()) { sum += x; } sum`, "unknown operator: -BOOLEAN"},
+		{`yield 1;`, "yield outside of generator"},
+		{`for (x in 5) { }`, "cannot iterate over INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestGeneratorClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	gen, ok := testEval(`let g = fn() { yield 1; yield 2; }(); next(g); g`).(*object.Generator)
+	if !ok {
+		t.Fatalf("object is not Generator")
+	}
+
+	gen.Close()
+	if _, ok := gen.Next(); ok {
+		t.Errorf("closed generator produced a value")
+	}
+	// Other goroutines may come and go, so wait only until no more are
+	// running than before
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine of closed generator did not exit")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGeneratorPanic(t *testing.T) {
+	defer object.SetHost(object.SetHost(&object.Host{
+		Now: func() time.Time { panic("boom") },
+	}))
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("wrong panic. want=%q, got=%v", "boom", r)
+		}
+	}()
+	testEval(`let g = fn() { yield now(); }(); next(g)`)
+	t.Errorf("panic in generator body was not raised by next")
+}
+
+func TestBigIntegerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"18446744073709551616 - 18446744073709551615", "1"},
+		{"-18446744073709551616", "-18446744073709551616"},
+		{"1 / 0", "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: want=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	if _, ok := testEval("18446744073709551616 - 18446744073709551615").(*object.Integer); !ok {
+		t.Errorf("result in int64 range was not demoted to Integer")
+	}
+}
@@ -0,0 +1,73 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"runtime"
+)
+
+type generatorResult struct {
+	value object.Object
+	ok    bool
+	// panicked holds what the body panicked with, if it did
+	panicked any
+}
+
+// newGenerator returns a generator for a call of fn. The body runs on its
+// own goroutine, which is parked at every yield until the generator is
+// resumed again, so the evaluator's call stack is kept while suspended.
+// Closing the generator ends a parked goroutine, and a Go panic in the body
+// is raised again in the goroutine that resumed it.
+func newGenerator(fn *object.Function, args []object.Object) object.Object {
+	env := extendFunctionEnv(fn, args)
+	resume := make(chan bool)
+	results := make(chan generatorResult)
+	started, panicked := false, false
+
+	env.SetYield(func(value object.Object) {
+		results <- generatorResult{value: value, ok: true}
+		if !<-resume {
+			// The generator was closed while suspended
+			runtime.Goexit()
+		}
+	})
+
+	run := func() {
+		defer func() {
+			if r := recover(); r != nil {
+				results <- generatorResult{panicked: r}
+			}
+		}()
+
+		evaluated := unwrapReturnValue(Eval(fn.Body, env))
+		if isError(evaluated) {
+			results <- generatorResult{value: evaluated, ok: true}
+			return
+		}
+		results <- generatorResult{ok: false}
+	}
+
+	gen := object.NewGenerator(func() (object.Object, bool) {
+		if panicked {
+			return nil, false
+		}
+		if !started {
+			started = true
+			go run()
+		} else {
+			resume <- true
+		}
+		result := <-results
+		if result.panicked != nil {
+			panicked = true
+			panic(result.panicked)
+		}
+		return result.value, result.ok
+	}, func() {
+		close(resume)
+	})
+
+	// A generator dropped without being closed is closed once collected
+	runtime.SetFinalizer(gen, (*object.Generator).Close)
+
+	return gen
+}
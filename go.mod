@@ -1,3 +1,5 @@
 module monkey
 
 go 1.23
+
+require golang.org/x/text v0.21.0
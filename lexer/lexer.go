@@ -3,6 +3,8 @@ package lexer
 import (
 	"monkey/token"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
@@ -158,6 +160,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RPAREN, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '+':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -174,6 +178,15 @@ func (l *Lexer) NextToken() token.Token {
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
+	case '#':
+		if l.peekChar() == '{' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.SET_LBRACE, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -184,21 +197,24 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
-		if isLetter(l.ch) {
+		if l.ch == 'b' && l.peekChar() == '"' {
+			l.readChar()
+			tok.Type = token.BYTES
+			tok.Literal = l.readBytes()
+		} else if r, _ := l.currentRune(); isLetter(r) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			numberLiteral := l.readNumber()
-			tok.Literal = numberLiteral
-
-			// Check if it's a float by looking for a dot
-			if strings.Contains(numberLiteral, ".") {
-				tok.Type = token.FLOAT
-			} else {
-				tok.Type = token.INT
-			}
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
+		} else if l.ch >= utf8.RuneSelf {
+			// Report other non-ASCII characters whole rather than byte by byte
+			r, size := l.currentRune()
+			tok = token.Token{Type: token.ILLEGAL, Literal: string(r)}
+			for i := 1; i < size; i++ {
+				l.readChar()
+			}
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
@@ -215,43 +231,104 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 	}
 }
 
+// currentRune decodes the UTF-8 character starting at the current byte.
+func (l *Lexer) currentRune() (rune, int) {
+	if l.ch < utf8.RuneSelf {
+		return rune(l.ch), 1
+	}
+	return utf8.DecodeRuneInString(l.input[l.position:])
+}
+
+// readIdentifier reads a name made of letters, digits, combining marks and
+// underscores in any script, so that identifiers like 名前 are allowed.
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) || isDigit(l.ch) {
-		l.readChar()
+	for {
+		r, size := l.currentRune()
+		if !isLetter(r) && !unicode.IsDigit(r) && !unicode.In(r, unicode.Mn, unicode.Mc) {
+			break
+		}
+		for i := 0; i < size; i++ {
+			l.readChar()
+		}
 	}
 	identifier := l.input[position:l.position]
 	return l.internString(identifier)
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(r rune) bool {
+	if r < utf8.RuneSelf {
+		return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+	}
+	return unicode.IsLetter(r)
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or float literal. Integers may be written
+// in hex, octal or binary with a 0x, 0o or 0b prefix, floats may have an
+// exponent, and underscores may separate digits. Malformed digits are left
+// in the literal for the parser to report.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
-	for isDigit(l.ch) {
+	tokenType := token.TokenType(token.INT)
+
+	if l.ch == '0' && strings.ContainsRune("xXoObB", rune(l.peekChar())) {
+		l.readChar()
 		l.readChar()
+		for isHexDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return l.input[position:l.position], tokenType
 	}
 
+	l.readDigits()
+
 	// Handle floating point numbers
 	if l.ch == '.' && isDigit(l.peekChar()) {
-		// Consume the dot
+		tokenType = token.FLOAT
 		l.readChar()
+		l.readDigits()
+	}
 
-		// Read the decimal part
-		for isDigit(l.ch) {
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if next == '+' || next == '-' {
+			next = l.peekCharAt(2)
+		}
+		if isDigit(next) {
+			tokenType = token.FLOAT
 			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
 		}
 	}
 
-	return l.input[position:l.position]
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+}
+
+// peekCharAt returns the character offset positions after the current one.
+func (l *Lexer) peekCharAt(offset int) byte {
+	if l.position+offset >= len(l.input) {
+		return 0
+	}
+	return l.input[l.position+offset]
 }
 
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
@@ -283,6 +360,24 @@ func (l *Lexer) readString() string {
 	return l.internString(stringLiteral)
 }
 
+// readBytes reads the body of a bytes literal. Unlike strings, bytes
+// literals may contain escapes, so a quote preceded by a backslash does not
+// end the literal. The escapes are left in place for the parser to decode.
+func (l *Lexer) readBytes() string {
+	position := l.position + 1
+	for {
+		l.readChar()
+		if l.ch == '\\' && l.peekChar() != 0 {
+			l.readChar()
+			continue
+		}
+		if l.ch == '"' || l.ch == 0 {
+			break
+		}
+	}
+	return l.input[position:l.position]
+}
+
 func (l *Lexer) readSingleLineComment() string {
 	position := l.position
 	for l.ch != '\n' && l.ch != 0 {
@@ -332,3 +332,179 @@ let abc123def = 42;
 		}
 	}
 }
+
+func TestStructAndSetTokens(t *testing.T) {
+	input := `struct Point { x, y }
+impl Point { fn norm(self) { self.x } }
+p.x;
+1.5.y;
+2 in #{2};
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRUCT, "struct"},
+		{token.IDENT, "Point"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.COMMA, ","},
+		{token.IDENT, "y"},
+		{token.RBRACE, "}"},
+		{token.IMPL, "impl"},
+		{token.IDENT, "Point"},
+		{token.LBRACE, "{"},
+		{token.FUNCTION, "fn"},
+		{token.IDENT, "norm"},
+		{token.LPAREN, "("},
+		{token.IDENT, "self"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "self"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		{token.RBRACE, "}"},
+		{token.IDENT, "p"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "1.5"},
+		{token.DOT, "."},
+		{token.IDENT, "y"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "2"},
+		{token.IN, "in"},
+		{token.SET_LBRACE, "#{"},
+		{token.INT, "2"},
+		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestBytesTokens(t *testing.T) {
+	input := `b"abc" b"a\"b\x00" b"" bx`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.BYTES, "abc"},
+		{token.BYTES, `a\"b\x00`},
+		{token.BYTES, ""},
+		{token.IDENT, "bx"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let 名前 = "x"; café é1 → x`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "名前"},
+		{token.ASSIGN, "="},
+		{token.STRING, "x"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "café"},
+		{token.IDENT, "é1"},
+		{token.ILLEGAL, "→"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	input := `0xFF 0o17 0b1010 1_000_000 3.141_59 1e9 2.5E-3 6e+2 1.5.x 7e x1 0b12`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0xFF"},
+		{token.INT, "0o17"},
+		{token.INT, "0b1010"},
+		{token.INT, "1_000_000"},
+		{token.FLOAT, "3.141_59"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		{token.FLOAT, "1.5"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "7"},
+		{token.IDENT, "e"},
+		{token.IDENT, "x1"},
+		{token.INT, "0b12"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
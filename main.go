@@ -1,19 +1,40 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"monkey/object"
 	"monkey/repl"
 	"os"
 	"os/user"
+	"strings"
 )
 
+// dirList collects the directories of a flag that may be given repeatedly.
+type dirList []string
+
+func (d *dirList) String() string { return strings.Join(*d, ",") }
+
+func (d *dirList) Set(dir string) error {
+	*d = append(*d, dir)
+	return nil
+}
+
 func main() {
+	var roots dirList
+	flag.Var(&roots, "allow-dir", "let scripts access the files below `dir`; may be repeated")
+	flag.Parse()
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
+	// Scripts typed into the REPL have no file access unless directories
+	// are granted on the command line, and args() leaves out the flags
+	host := &object.Host{FileRoots: roots, Args: flag.Args()}
+
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	os.Exit(repl.Start(os.Stdin, os.Stdout, host))
 }
@@ -0,0 +1,151 @@
+package object
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// BigInt holds an integer that does not fit in an int64. Integer
+// arithmetic promotes to BigInt on overflow and demotes back to Integer as
+// soon as a result fits again, so a BigInt is never within int64 range.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (bi *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (bi *BigInt) Inspect() string  { return bi.Value.String() }
+
+func (bi *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	if bi.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(bi.Value.Bytes())
+	return HashKey{Type: bi.Type(), Value: h.Sum64()}
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+// NewBigInt returns value as an Integer if it fits in an int64 and as a
+// BigInt otherwise.
+func NewBigInt(value *big.Int) Object {
+	if value.IsInt64() {
+		return NewInteger(value.Int64())
+	}
+	return &BigInt{Value: value}
+}
+
+// IsInteger reports whether obj is an Integer or a BigInt.
+func IsInteger(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInt:
+		return true
+	default:
+		return false
+	}
+}
+
+// IntegerToFloat converts an Integer or BigInt to the nearest float64.
+func IntegerToFloat(obj Object) float64 {
+	if bi, ok := obj.(*BigInt); ok {
+		f, _ := new(big.Float).SetInt(bi.Value).Float64()
+		return f
+	}
+	return float64(obj.(*Integer).Value)
+}
+
+func toBigInt(obj Object) *big.Int {
+	if bi, ok := obj.(*BigInt); ok {
+		return bi.Value
+	}
+	return big.NewInt(obj.(*Integer).Value)
+}
+
+// IntegerArithmetic applies one of the operators +, -, * and / to two
+// integers. int64 arithmetic is used while it cannot overflow; otherwise the
+// result is computed with math/big. Division truncates towards zero.
+func IntegerArithmetic(operator string, left, right Object) (Object, error) {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		if result, ok := int64Arithmetic(operator, l.Value, r.Value); ok {
+			return NewInteger(result), nil
+		}
+	}
+
+	a, b := toBigInt(left), toBigInt(right)
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		result.Add(a, b)
+	case "-":
+		result.Sub(a, b)
+	case "*":
+		result.Mul(a, b)
+	case "/":
+		if b.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		result.Quo(a, b)
+	default:
+		return nil, errors.New("unknown integer operator: " + operator)
+	}
+	return NewBigInt(result), nil
+}
+
+// int64Arithmetic reports false if the operation overflows or divides by
+// zero, leaving those cases to math/big.
+func int64Arithmetic(operator string, a, b int64) (int64, bool) {
+	switch operator {
+	case "+":
+		result := a + b
+		return result, (result > a) == (b > 0)
+	case "-":
+		result := a - b
+		return result, (result < a) == (b > 0)
+	case "*":
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		result := a * b
+		if result/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return 0, false
+		}
+		return result, true
+	case "/":
+		if b == 0 || (a == math.MinInt64 && b == -1) {
+			return 0, false
+		}
+		return a / b, true
+	default:
+		return 0, false
+	}
+}
+
+// NegateInteger returns -obj for an Integer or BigInt.
+func NegateInteger(obj Object) Object {
+	if i, ok := obj.(*Integer); ok && i.Value != math.MinInt64 {
+		return NewInteger(-i.Value)
+	}
+	return NewBigInt(new(big.Int).Neg(toBigInt(obj)))
+}
+
+// CompareIntegers returns -1, 0 or +1 depending on whether left is less
+// than, equal to or greater than right.
+func CompareIntegers(left, right Object) int {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		switch {
+		case l.Value < r.Value:
+			return -1
+		case l.Value > r.Value:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return toBigInt(left).Cmp(toBigInt(right))
+}
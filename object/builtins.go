@@ -1,11 +1,23 @@
 package object
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math"
-	"regexp"
+	"math/big"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 var Builtins = []struct {
@@ -23,10 +35,14 @@ var Builtins = []struct {
 			case *Array:
 				return NewInteger(int64(len(arg.Elements)))
 			case *String:
+				return NewInteger(int64(StringLength(arg.Value)))
+			case *Bytes:
 				return NewInteger(int64(len(arg.Value)))
+			case *Set:
+				return NewInteger(int64(arg.Len()))
 			default:
 				return newError("argument to `len` not supported, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 		},
 		},
@@ -51,9 +67,13 @@ var Builtins = []struct {
 			if args[0] == nil {
 				return newError("argument to `first` cannot be nil")
 			}
+			if gen, ok := args[0].(*Generator); ok {
+				value, _ := gen.Next()
+				return value
+			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `first` must be ARRAY, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			arr := args[0].(*Array)
@@ -78,7 +98,7 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `last` must be ARRAY, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			arr := args[0].(*Array)
@@ -101,17 +121,21 @@ var Builtins = []struct {
 			if args[0] == nil {
 				return newError("argument to `rest` cannot be nil")
 			}
+			if gen, ok := args[0].(*Generator); ok {
+				// Skip one value and hand back the same, lazily consumed generator
+				if value, ok := gen.Next(); ok && value.Type() == ERROR_OBJ {
+					return value
+				}
+				return gen
+			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `rest` must be ARRAY, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			arr := args[0].(*Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &Array{Elements: newElements}
+			if len(arr.Elements) > 0 {
+				return arr.Rest()
 			}
 
 			return nil
@@ -130,17 +154,11 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `push` must be ARRAY, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			arr := args[0].(*Array)
-			length := len(arr.Elements)
-
-			newElements := make([]Object, length+1, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-
-			return &Array{Elements: newElements}
+			return arr.Push(args[1])
 		},
 		},
 	},
@@ -156,15 +174,12 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("argument to `pop` must be ARRAY, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			arr := args[0].(*Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]Object, length-1, length-1)
-				copy(newElements, arr.Elements[0:length-1])
-				return &Array{Elements: newElements}
+			if len(arr.Elements) > 0 {
+				return arr.Pop()
 			}
 
 			return nil
@@ -183,7 +198,7 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != STRING_OBJ {
 				return newError("argument to `upper` must be STRING, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			str := args[0].(*String)
@@ -203,7 +218,7 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != STRING_OBJ {
 				return newError("argument to `lower` must be STRING, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			str := args[0].(*String)
@@ -226,11 +241,11 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != STRING_OBJ {
 				return newError("first argument to `split` must be STRING, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 			if args[1].Type() != STRING_OBJ {
 				return newError("second argument to `split` must be STRING, got %s",
-					args[1].Type())
+					TypeName(args[1]))
 			}
 
 			str := args[0].(*String)
@@ -265,11 +280,11 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != ARRAY_OBJ {
 				return newError("first argument to `join` must be ARRAY, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 			if args[1].Type() != STRING_OBJ {
 				return newError("second argument to `join` must be STRING, got %s",
-					args[1].Type())
+					TypeName(args[1]))
 			}
 
 			arr := args[0].(*Array)
@@ -303,17 +318,16 @@ var Builtins = []struct {
 			}
 
 			switch arg := args[0].(type) {
-			case *Integer:
-				value := arg.Value
-				if value < 0 {
-					value = -value
+			case *Integer, *BigInt:
+				if CompareIntegers(arg, NewInteger(0)) < 0 {
+					return NegateInteger(arg)
 				}
-				return NewInteger(value)
+				return arg
 			case *Float:
 				return &Float{Value: math.Abs(arg.Value)}
 			default:
 				return newError("argument to `abs` must be INTEGER or FLOAT, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 		},
 		},
@@ -321,114 +335,28 @@ var Builtins = []struct {
 	{
 		"min",
 		&Builtin{Fn: func(args ...Object) Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
-			}
-			if args[0] == nil {
-				return newError("first argument to `min` cannot be nil")
-			}
-			if args[1] == nil {
-				return newError("second argument to `min` cannot be nil")
-			}
-
-			// Check first argument type
-			var val1 float64
-			var isFloat1 bool
-			switch arg := args[0].(type) {
-			case *Integer:
-				val1 = float64(arg.Value)
-				isFloat1 = false
-			case *Float:
-				val1 = arg.Value
-				isFloat1 = true
-			default:
-				return newError("first argument to `min` must be INTEGER or FLOAT, got %s",
-					args[0].Type())
-			}
-
-			// Check second argument type
-			var val2 float64
-			var isFloat2 bool
-			switch arg := args[1].(type) {
-			case *Integer:
-				val2 = float64(arg.Value)
-				isFloat2 = false
-			case *Float:
-				val2 = arg.Value
-				isFloat2 = true
-			default:
-				return newError("second argument to `min` must be INTEGER or FLOAT, got %s",
-					args[1].Type())
-			}
-
-			// If either argument is float, result is float
-			if isFloat1 || isFloat2 {
-				return &Float{Value: math.Min(val1, val2)}
+			numbers, err := numbersArgument("min", args)
+			if err != nil {
+				return err
 			}
-
-			// Both are integers, return integer
-			if val1 < val2 {
-				return args[0]
+			if len(numbers) == 0 {
+				return newError("`min` of empty array")
 			}
-			return args[1]
+			return extremum(numbers, -1)
 		},
 		},
 	},
 	{
 		"max",
 		&Builtin{Fn: func(args ...Object) Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
-			}
-			if args[0] == nil {
-				return newError("first argument to `max` cannot be nil")
-			}
-			if args[1] == nil {
-				return newError("second argument to `max` cannot be nil")
-			}
-
-			// Check first argument type
-			var val1 float64
-			var isFloat1 bool
-			switch arg := args[0].(type) {
-			case *Integer:
-				val1 = float64(arg.Value)
-				isFloat1 = false
-			case *Float:
-				val1 = arg.Value
-				isFloat1 = true
-			default:
-				return newError("first argument to `max` must be INTEGER or FLOAT, got %s",
-					args[0].Type())
-			}
-
-			// Check second argument type
-			var val2 float64
-			var isFloat2 bool
-			switch arg := args[1].(type) {
-			case *Integer:
-				val2 = float64(arg.Value)
-				isFloat2 = false
-			case *Float:
-				val2 = arg.Value
-				isFloat2 = true
-			default:
-				return newError("second argument to `max` must be INTEGER or FLOAT, got %s",
-					args[1].Type())
-			}
-
-			// If either argument is float, result is float
-			if isFloat1 || isFloat2 {
-				return &Float{Value: math.Max(val1, val2)}
+			numbers, err := numbersArgument("max", args)
+			if err != nil {
+				return err
 			}
-
-			// Both are integers, return integer
-			if val1 > val2 {
-				return args[0]
+			if len(numbers) == 0 {
+				return newError("`max` of empty array")
 			}
-			return args[1]
+			return extremum(numbers, 1)
 		},
 		},
 	},
@@ -451,7 +379,7 @@ var Builtins = []struct {
 				value = arg.Value
 			default:
 				return newError("argument to `sqrt` must be INTEGER or FLOAT, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			if value < 0 {
@@ -465,8 +393,8 @@ var Builtins = []struct {
 	{
 		"regex",
 		&Builtin{Fn: func(args ...Object) Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
 					len(args))
 			}
 			if args[0] == nil {
@@ -474,16 +402,26 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != STRING_OBJ {
 				return newError("argument to `regex` must be STRING, got %s",
-					args[0].Type())
+					TypeName(args[0]))
+			}
+
+			flags := ""
+			if len(args) == 2 {
+				flagsArg, ok := args[1].(*String)
+				if !ok {
+					return newError("flags argument to `regex` must be STRING, got %s",
+						TypeName(args[1]))
+				}
+				flags = flagsArg.Value
 			}
 
 			pattern := args[0].(*String).Value
-			re, err := regexp.Compile(pattern)
+			re, err := NewRegex(pattern, flags)
 			if err != nil {
 				return newError("invalid regex pattern: %s", err.Error())
 			}
 
-			return &Regex{Pattern: pattern, Regexp: re}
+			return re
 		},
 		},
 	},
@@ -502,11 +440,11 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != REGEX_OBJ {
 				return newError("first argument to `match` must be REGEX, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 			if args[1].Type() != STRING_OBJ {
 				return newError("second argument to `match` must be STRING, got %s",
-					args[1].Type())
+					TypeName(args[1]))
 			}
 
 			regex := args[0].(*Regex)
@@ -528,7 +466,7 @@ var Builtins = []struct {
 	},
 	{
 		"replace",
-		&Builtin{Fn: func(args ...Object) Object {
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
 			if len(args) != 3 {
 				return newError("wrong number of arguments. got=%d, want=3",
 					len(args))
@@ -544,23 +482,21 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != STRING_OBJ {
 				return newError("first argument to `replace` must be STRING, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 			if args[1].Type() != REGEX_OBJ {
 				return newError("second argument to `replace` must be REGEX, got %s",
-					args[1].Type())
+					TypeName(args[1]))
 			}
-			if args[2].Type() != STRING_OBJ {
-				return newError("third argument to `replace` must be STRING, got %s",
-					args[2].Type())
+			if args[2].Type() != STRING_OBJ && !IsCallable(args[2]) {
+				return newError("third argument to `replace` must be STRING or FUNCTION, got %s",
+					TypeName(args[2]))
 			}
 
 			text := args[0].(*String).Value
 			regex := args[1].(*Regex)
-			replacement := args[2].(*String).Value
 
-			result := regex.Regexp.ReplaceAllString(text, replacement)
-			return &String{Value: result}
+			return ReplaceRegex(caller, text, regex.Regexp, args[2])
 		},
 		},
 	},
@@ -579,11 +515,11 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != STRING_OBJ {
 				return newError("first argument to `regex_split` must be STRING, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 			if args[1].Type() != REGEX_OBJ {
 				return newError("second argument to `regex_split` must be REGEX, got %s",
-					args[1].Type())
+					TypeName(args[1]))
 			}
 
 			text := args[0].(*String).Value
@@ -611,20 +547,24 @@ var Builtins = []struct {
 			}
 			if args[0].Type() != STRING_OBJ {
 				return newError("argument to `json_parse` must be STRING, got %s",
-					args[0].Type())
+					TypeName(args[0]))
 			}
 
 			jsonStr := args[0].(*String).Value
 
-			// Parse JSON string
-			var jsonValue interface{}
-			err := json.Unmarshal([]byte(jsonStr), &jsonValue)
+			// Parse JSON string, keeping numbers exact so that large
+			// integers become BigInts
+			decoder := json.NewDecoder(strings.NewReader(jsonStr))
+			decoder.UseNumber()
+			result, err := decodeJSON(decoder)
 			if err != nil {
 				return newError("invalid JSON: %s", err.Error())
 			}
+			if _, err := decoder.Token(); err != io.EOF {
+				return newError("invalid JSON: unexpected data after top-level value")
+			}
 
-			// Convert Go interface{} to Monkey Object
-			return convertGoValueToMonkeyObject(jsonValue)
+			return result
 		},
 		},
 	},
@@ -654,7 +594,7 @@ var Builtins = []struct {
 				}
 				if args[1].Type() != STRING_OBJ {
 					return newError("second argument to `json_stringify` must be STRING, got %s",
-						args[1].Type())
+						TypeName(args[1]))
 				}
 				indent := args[1].(*String).Value
 				jsonBytes, err = json.MarshalIndent(goValue, "", indent)
@@ -663,6 +603,12 @@ var Builtins = []struct {
 			}
 
 			if err != nil {
+				// Report the underlying problem rather than which
+				// MarshalJSON method ran into it
+				var marshalerErr *json.MarshalerError
+				for errors.As(err, &marshalerErr) {
+					err = marshalerErr.Err
+				}
 				return newError("JSON stringify error: %s", err.Error())
 			}
 
@@ -670,84 +616,2235 @@ var Builtins = []struct {
 		},
 		},
 	},
-}
-
-// convertGoValueToMonkeyObject converts Go interface{} to Monkey Object
-func convertGoValueToMonkeyObject(value interface{}) Object {
-	switch v := value.(type) {
-	case nil:
-		return NULL
-	case bool:
-		if v {
-			return TRUE
-		}
-		return FALSE
-	case float64:
-		// JSON numbers are always float64, but check if it's actually an integer
-		if v == float64(int64(v)) {
-			return NewInteger(int64(v))
-		}
-		return &Float{Value: v}
-	case string:
-		return &String{Value: v}
-	case []interface{}:
-		elements := make([]Object, len(v))
-		for i, elem := range v {
-			elements[i] = convertGoValueToMonkeyObject(elem)
-		}
-		return &Array{Elements: elements}
-	case map[string]interface{}:
-		pairs := make(map[HashKey]HashPair)
-		for key, val := range v {
-			keyObj := &String{Value: key}
-			valueObj := convertGoValueToMonkeyObject(val)
-			hashKey := keyObj.HashKey()
-			pairs[hashKey] = HashPair{Key: keyObj, Value: valueObj}
-		}
-		return &Hash{Pairs: pairs}
-	default:
-		return NULL
-	}
-}
+	{
+		"next",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0] == nil {
+				return newError("argument to `next` cannot be nil")
+			}
+			gen, ok := args[0].(*Generator)
+			if !ok {
+				return newError("argument to `next` must be GENERATOR, got %s",
+					TypeName(args[0]))
+			}
 
-// convertMonkeyObjectToGoValue converts Monkey Object to Go interface{}
-func convertMonkeyObjectToGoValue(obj Object) (interface{}, bool) {
-	switch o := obj.(type) {
-	case *Integer:
-		return o.Value, true
-	case *Float:
-		return o.Value, true
-	case *Boolean:
-		return o.Value, true
-	case *String:
-		return o.Value, true
-	case *Array:
-		result := make([]interface{}, len(o.Elements))
-		for i, elem := range o.Elements {
-			val, ok := convertMonkeyObjectToGoValue(elem)
+			value, ok := gen.Next()
 			if !ok {
-				return nil, false
+				return NULL
 			}
-			result[i] = val
-		}
-		return result, true
-	case *Hash:
-		result := make(map[string]interface{})
-		for _, pair := range o.Pairs {
-			if keyStr, ok := pair.Key.(*String); ok {
-				val, ok := convertMonkeyObjectToGoValue(pair.Value)
+			return value
+		},
+		},
+	},
+	{
+		"set",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1",
+					len(args))
+			}
+			set := NewSet()
+			if len(args) == 0 {
+				return set
+			}
+			if args[0] == nil {
+				return newError("argument to `set` cannot be nil")
+			}
+			iterator, ok := Iterate(args[0])
+			if !ok {
+				return newError("argument to `set` must be iterable, got %s",
+					TypeName(args[0]))
+			}
+			for {
+				value, ok := iterator.Next()
 				if !ok {
-					return nil, false
+					return set
+				}
+				if err, isError := value.(*Error); isError {
+					return err
+				}
+				if !set.Add(value) {
+					return newError("unusable as set element: %s", TypeName(value))
 				}
-				result[keyStr.Value] = val
 			}
-		}
-		return result, true
-	case *Null:
-		return nil, true
-	default:
-		return nil, false
-	}
+		},
+		},
+	},
+	{
+		"union",
+		&Builtin{Fn: func(args ...Object) Object {
+			a, b, err := setArguments("union", args)
+			if err != nil {
+				return err
+			}
+			return a.Union(b)
+		},
+		},
+	},
+	{
+		"intersection",
+		&Builtin{Fn: func(args ...Object) Object {
+			a, b, err := setArguments("intersection", args)
+			if err != nil {
+				return err
+			}
+			return a.Intersection(b)
+		},
+		},
+	},
+	{
+		"difference",
+		&Builtin{Fn: func(args ...Object) Object {
+			a, b, err := setArguments("difference", args)
+			if err != nil {
+				return err
+			}
+			return a.Difference(b)
+		},
+		},
+	},
+	{
+		"encode",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			if args[0] == nil {
+				return newError("first argument to `encode` cannot be nil")
+			}
+			str, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `encode` must be STRING, got %s",
+					TypeName(args[0]))
+			}
+			encoding, err := encodingArgument("encode", args)
+			if err != nil {
+				return err
+			}
+			data, encodeErr := Encode(str.Value, encoding)
+			if encodeErr != nil {
+				return newError("%s", encodeErr)
+			}
+			return &Bytes{Value: data}
+		},
+		},
+	},
+	{
+		"decode",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			if args[0] == nil {
+				return newError("first argument to `decode` cannot be nil")
+			}
+			data, ok := args[0].(*Bytes)
+			if !ok {
+				return newError("first argument to `decode` must be BYTES, got %s",
+					TypeName(args[0]))
+			}
+			encoding, err := encodingArgument("decode", args)
+			if err != nil {
+				return err
+			}
+			str, decodeErr := Decode(data.Value, encoding)
+			if decodeErr != nil {
+				return newError("%s", decodeErr)
+			}
+			return &String{Value: str}
+		},
+		},
+	},
+	{
+		"base64_encode",
+		&Builtin{Fn: func(args ...Object) Object {
+			data, err := bytesArgument("base64_encode", args)
+			if err != nil {
+				return err
+			}
+			return &String{Value: base64.StdEncoding.EncodeToString(data)}
+		},
+		},
+	},
+	{
+		"base64_decode",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := stringArgument("base64_decode", args)
+			if err != nil {
+				return err
+			}
+			// Accept both alphabets, with or without padding, as tokens
+			// commonly use unpadded URL-safe base64
+			encoding := base64.StdEncoding
+			if strings.ContainsAny(str, "-_") {
+				encoding = base64.URLEncoding
+			}
+			if !strings.HasSuffix(str, "=") {
+				encoding = encoding.WithPadding(base64.NoPadding)
+			}
+			data, decodeErr := encoding.DecodeString(str)
+			if decodeErr != nil {
+				return newError("invalid base64: %s", decodeErr)
+			}
+			return &Bytes{Value: data}
+		},
+		},
+	},
+	{
+		"hex_encode",
+		&Builtin{Fn: func(args ...Object) Object {
+			data, err := bytesArgument("hex_encode", args)
+			if err != nil {
+				return err
+			}
+			return &String{Value: hex.EncodeToString(data)}
+		},
+		},
+	},
+	{
+		"hex_decode",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := stringArgument("hex_decode", args)
+			if err != nil {
+				return err
+			}
+			data, decodeErr := hex.DecodeString(str)
+			if decodeErr != nil {
+				return newError("invalid hex: %s", decodeErr)
+			}
+			return &Bytes{Value: data}
+		},
+		},
+	},
+	{
+		"url_encode",
+		&Builtin{Fn: func(args ...Object) Object {
+			data, err := bytesArgument("url_encode", args)
+			if err != nil {
+				return err
+			}
+			return &String{Value: url.QueryEscape(string(data))}
+		},
+		},
+	},
+	{
+		"url_decode",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := stringArgument("url_decode", args)
+			if err != nil {
+				return err
+			}
+			decoded, decodeErr := url.QueryUnescape(str)
+			if decodeErr != nil {
+				return newError("invalid URL encoding: %s", decodeErr)
+			}
+			return &String{Value: decoded}
+		},
+		},
+	},
+	{
+		"now",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			return &Time{Value: hostOf(caller).now()}
+		},
+		},
+	},
+	{
+		"time",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 3 && len(args) != 6 && len(args) != 7 {
+				return newError("wrong number of arguments. got=%d, want=3, 6 or 7",
+					len(args))
+			}
+			// year, month, day[, hour, minute, second[, zone]]
+			parts := make([]int, 6)
+			for i, arg := range args[:min(len(args), 6)] {
+				integer, ok := arg.(*Integer)
+				if !ok {
+					return integerError("arguments to `time`", arg)
+				}
+				parts[i] = int(integer.Value)
+			}
+			// time.Date would carry values out of range into the next
+			// field, e.g. month 13 into the next year
+			if parts[1] < 1 || parts[1] > 12 {
+				return newError("invalid month: %d", parts[1])
+			}
+			days := time.Date(parts[0], time.Month(parts[1])+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			if parts[2] < 1 || parts[2] > days {
+				return newError("invalid day: %d", parts[2])
+			}
+			if parts[3] < 0 || parts[3] > 23 {
+				return newError("invalid hour: %d", parts[3])
+			}
+			if parts[4] < 0 || parts[4] > 59 {
+				return newError("invalid minute: %d", parts[4])
+			}
+			if parts[5] < 0 || parts[5] > 59 {
+				return newError("invalid second: %d", parts[5])
+			}
+			location := time.UTC
+			if len(args) == 7 {
+				loc, err := locationArgument("time", args[6])
+				if err != nil {
+					return err
+				}
+				location = loc
+			}
+			return &Time{Value: time.Date(parts[0], time.Month(parts[1]), parts[2],
+				parts[3], parts[4], parts[5], 0, location)}
+		},
+		},
+	},
+	{
+		"parse_time",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3",
+					len(args))
+			}
+			str, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `parse_time` must be STRING, got %s",
+					TypeName(args[0]))
+			}
+			layout, ok := args[1].(*String)
+			if !ok {
+				return newError("second argument to `parse_time` must be STRING, got %s",
+					TypeName(args[1]))
+			}
+			location := time.UTC
+			if len(args) == 3 {
+				loc, err := locationArgument("parse_time", args[2])
+				if err != nil {
+					return err
+				}
+				location = loc
+			}
+			t, err := time.ParseInLocation(Layout(layout.Value), str.Value, location)
+			if err != nil {
+				return newError("invalid time: %s", err)
+			}
+			return &Time{Value: t}
+		},
+		},
+	},
+	{
+		"format_time",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			t, ok := args[0].(*Time)
+			if !ok {
+				return newError("first argument to `format_time` must be TIME, got %s",
+					TypeName(args[0]))
+			}
+			layout, ok := args[1].(*String)
+			if !ok {
+				return newError("second argument to `format_time` must be STRING, got %s",
+					TypeName(args[1]))
+			}
+			return &String{Value: t.Value.Format(Layout(layout.Value))}
+		},
+		},
+	},
+	{
+		"duration",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := stringArgument("duration", args)
+			if err != nil {
+				return err
+			}
+			d, parseErr := time.ParseDuration(str)
+			if parseErr != nil {
+				return newError("invalid duration: %s", parseErr)
+			}
+			return &Duration{Value: d}
+		},
+		},
+	},
+	{
+		"in_zone",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			t, ok := args[0].(*Time)
+			if !ok {
+				return newError("first argument to `in_zone` must be TIME, got %s",
+					TypeName(args[0]))
+			}
+			location, err := locationArgument("in_zone", args[1])
+			if err != nil {
+				return err
+			}
+			return &Time{Value: t.Value.In(location)}
+		},
+		},
+	},
+	{
+		"chars",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := stringArgument("chars", args)
+			if err != nil {
+				return err
+			}
+			clusters := Graphemes(str)
+			elements := make([]Object, len(clusters))
+			for i, cluster := range clusters {
+				elements[i] = &String{Value: cluster}
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"runes",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := stringArgument("runes", args)
+			if err != nil {
+				return err
+			}
+			elements := []Object{}
+			for _, r := range str {
+				elements = append(elements, NewInteger(int64(r)))
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"normalize",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			str, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `normalize` must be STRING, got %s",
+					TypeName(args[0]))
+			}
+			form := "NFC"
+			if len(args) == 2 {
+				formArg, ok := args[1].(*String)
+				if !ok {
+					return newError("second argument to `normalize` must be STRING, got %s",
+						TypeName(args[1]))
+				}
+				form = formArg.Value
+			}
+			normalized, err := Normalize(str.Value, form)
+			if err != nil {
+				return newError("%s", err)
+			}
+			return &String{Value: normalized}
+		},
+		},
+	},
+	{
+		"match_all",
+		&Builtin{Fn: func(args ...Object) Object {
+			regex, text, err := regexArguments("match_all", args)
+			if err != nil {
+				return err
+			}
+
+			elements := []Object{}
+			for _, loc := range regex.Regexp.FindAllStringSubmatchIndex(text, -1) {
+				elements = append(elements, matchGroups(text, loc))
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"named_groups",
+		&Builtin{Fn: func(args ...Object) Object {
+			regex, text, err := regexArguments("named_groups", args)
+			if err != nil {
+				return err
+			}
+
+			loc := regex.Regexp.FindStringSubmatchIndex(text)
+			if loc == nil {
+				return NULL
+			}
+			return namedGroups(regex.Regexp, text, loc)
+		},
+		},
+	},
+	{
+		"match_positions",
+		&Builtin{Fn: func(args ...Object) Object {
+			regex, text, err := regexArguments("match_positions", args)
+			if err != nil {
+				return err
+			}
+
+			counter := &codePointCounter{text: text}
+			elements := []Object{}
+			for _, loc := range regex.Regexp.FindAllStringIndex(text, -1) {
+				start := NewInteger(int64(counter.offset(loc[0])))
+				end := NewInteger(int64(counter.offset(loc[1])))
+				elements = append(elements, &Array{Elements: []Object{start, end}})
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"map",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("map", args)
+			if err != nil {
+				return err
+			}
+			elements := []Object{}
+			err = eachResult(caller, iterator, fn, func(_, result Object) bool {
+				elements = append(elements, result)
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"filter",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("filter", args)
+			if err != nil {
+				return err
+			}
+			elements := []Object{}
+			err = eachResult(caller, iterator, fn, func(value, result Object) bool {
+				if IsTruthy(result) {
+					elements = append(elements, value)
+				}
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"reduce",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3",
+					len(args))
+			}
+			iterator, fn, err := callbackArguments("reduce", args[:2])
+			if err != nil {
+				return err
+			}
+
+			var accumulator Object
+			if len(args) == 3 {
+				accumulator = args[2]
+			} else {
+				first, ok, err := nextValue(iterator)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return newError("`reduce` of empty iterable with no initial value")
+				}
+				accumulator = first
+			}
+
+			for {
+				value, ok, err := nextValue(iterator)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return accumulator
+				}
+				accumulator = caller.Call(fn, accumulator, value)
+				if accumulator.Type() == ERROR_OBJ {
+					return accumulator
+				}
+			}
+		},
+		},
+	},
+	{
+		"each",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("each", args)
+			if err != nil {
+				return err
+			}
+			err = eachResult(caller, iterator, fn, func(_, _ Object) bool { return true })
+			if err != nil {
+				return err
+			}
+			return NULL
+		},
+		},
+	},
+	{
+		"find",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("find", args)
+			if err != nil {
+				return err
+			}
+			var found Object = NULL
+			err = eachResult(caller, iterator, fn, func(value, result Object) bool {
+				if IsTruthy(result) {
+					found = value
+					return false
+				}
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			return found
+		},
+		},
+	},
+	{
+		"any",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("any", args)
+			if err != nil {
+				return err
+			}
+			result := FALSE
+			err = eachResult(caller, iterator, fn, func(_, value Object) bool {
+				if IsTruthy(value) {
+					result = TRUE
+				}
+				return result == FALSE
+			})
+			if err != nil {
+				return err
+			}
+			return result
+		},
+		},
+	},
+	{
+		"all",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("all", args)
+			if err != nil {
+				return err
+			}
+			result := TRUE
+			err = eachResult(caller, iterator, fn, func(_, value Object) bool {
+				if !IsTruthy(value) {
+					result = FALSE
+				}
+				return result == TRUE
+			})
+			if err != nil {
+				return err
+			}
+			return result
+		},
+		},
+	},
+	{
+		"flat_map",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("flat_map", args)
+			if err != nil {
+				return err
+			}
+			elements := []Object{}
+			var resultErr *Error
+			err = eachResult(caller, iterator, fn, func(_, result Object) bool {
+				array, ok := result.(*Array)
+				if !ok {
+					resultErr = newError("function passed to `flat_map` must return ARRAY, got %s",
+						TypeName(result))
+					return false
+				}
+				elements = append(elements, array.Elements...)
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			if resultErr != nil {
+				return resultErr
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"zip",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 2 {
+				return newError("wrong number of arguments. got=%d, want at least 2",
+					len(args))
+			}
+			iterators := make([]Iterator, len(args))
+			for i, arg := range args {
+				iterator, err := iterableArgument("zip", argumentName(args, i), arg)
+				if err != nil {
+					return err
+				}
+				iterators[i] = iterator
+			}
+
+			elements := []Object{}
+			for {
+				tuple := make([]Object, len(iterators))
+				for i, iterator := range iterators {
+					value, ok, err := nextValue(iterator)
+					if err != nil {
+						return err
+					}
+					if !ok {
+						return &Array{Elements: elements}
+					}
+					tuple[i] = value
+				}
+				elements = append(elements, &Array{Elements: tuple})
+			}
+		},
+		},
+	},
+	{
+		"sort",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			iterator, err := iterableArgument("sort", "first argument", args[0])
+			if err != nil {
+				return err
+			}
+
+			compare := func(a, b Object) (int, *Error) { return Order(a, b), nil }
+			if len(args) == 2 {
+				if !IsCallable(args[1]) {
+					return newError("second argument to `sort` must be FUNCTION, got %s",
+						TypeName(args[1]))
+				}
+				compare = func(a, b Object) (int, *Error) {
+					return comparatorResult(caller.Call(args[1], a, b))
+				}
+			}
+
+			values, err := sortedValues(iterator, compare)
+			if err != nil {
+				return err
+			}
+			return &Array{Elements: values}
+		},
+		},
+	},
+	{
+		"sort_by",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			iterator, fn, err := callbackArguments("sort_by", args)
+			if err != nil {
+				return err
+			}
+
+			// Call fn once per value rather than once per comparison
+			keyed := []Object{}
+			err = eachResult(caller, iterator, fn, func(value, key Object) bool {
+				keyed = append(keyed, &Array{Elements: []Object{key, value}})
+				return true
+			})
+			if err != nil {
+				return err
+			}
+
+			pairs, _ := sortedValues(&arrayIterator{elements: keyed}, func(a, b Object) (int, *Error) {
+				return Order(a.(*Array).Elements[0], b.(*Array).Elements[0]), nil
+			})
+			values := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				values[i] = pair.(*Array).Elements[1]
+			}
+			return &Array{Elements: values}
+		},
+		},
+	},
+	{
+		"reverse",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Array:
+				elements := slices.Clone(arg.Elements)
+				slices.Reverse(elements)
+				return &Array{Elements: elements}
+			case *String:
+				clusters := Graphemes(arg.Value)
+				slices.Reverse(clusters)
+				return &String{Value: strings.Join(clusters, "")}
+			default:
+				return newError("argument to `reverse` must be ARRAY or STRING, got %s",
+					TypeName(args[0]))
+			}
+		},
+		},
+	},
+	{
+		"read_file",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "read_file", args, 1)
+			if err != nil {
+				return err
+			}
+			content, readErr := os.ReadFile(path)
+			if readErr != nil {
+				return newError("%s", readErr)
+			}
+			return &String{Value: string(content)}
+		},
+		},
+	},
+	{
+		"write_file",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "write_file", args, 2)
+			if err != nil {
+				return err
+			}
+			return writeFile("write_file", path, args[1], os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		},
+		},
+	},
+	{
+		"append_file",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "append_file", args, 2)
+			if err != nil {
+				return err
+			}
+			return writeFile("append_file", path, args[1], os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+		},
+		},
+	},
+	{
+		"list_dir",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "list_dir", args, 1)
+			if err != nil {
+				return err
+			}
+			entries, readErr := os.ReadDir(path)
+			if readErr != nil {
+				return newError("%s", readErr)
+			}
+			names := make([]Object, len(entries))
+			for i, entry := range entries {
+				names[i] = &String{Value: entry.Name()}
+			}
+			return &Array{Elements: names}
+		},
+		},
+	},
+	{
+		"exists",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "exists", args, 1)
+			if err != nil {
+				return err
+			}
+			if _, statErr := os.Stat(path); statErr != nil {
+				return FALSE
+			}
+			return TRUE
+		},
+		},
+	},
+	{
+		"mkdir",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "mkdir", args, 1)
+			if err != nil {
+				return err
+			}
+			if mkdirErr := os.MkdirAll(path, 0o755); mkdirErr != nil {
+				return newError("%s", mkdirErr)
+			}
+			return NULL
+		},
+		},
+	},
+	{
+		"remove",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "remove", args, 1)
+			if err != nil {
+				return err
+			}
+			if hostOf(caller).isFileRoot(path) {
+				return newError("cannot remove %s: it is one of the allowed directories",
+					args[0].Inspect())
+			}
+			if removeErr := os.Remove(path); removeErr != nil {
+				return newError("%s", removeErr)
+			}
+			return NULL
+		},
+		},
+	},
+	{
+		"file_lines",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			path, err := pathArgument(caller, "file_lines", args, 1)
+			if err != nil {
+				return err
+			}
+			file, openErr := os.Open(path)
+			if openErr != nil {
+				return newError("%s", openErr)
+			}
+			return fileLines(file)
+		},
+		},
+	},
+	{
+		"sum",
+		&Builtin{Fn: func(args ...Object) Object {
+			numbers, err := numbersArgument("sum", args)
+			if err != nil {
+				return err
+			}
+			return sumNumbers(numbers)
+		},
+		},
+	},
+	{
+		"clamp",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3",
+					len(args))
+			}
+			for i := range args {
+				if _, err := numberArgument("clamp", args, i); err != nil {
+					return err
+				}
+			}
+			if Order(args[1], args[2]) > 0 {
+				return newError("lower bound of `clamp` is greater than upper bound")
+			}
+			return extremum([]Object{extremum([]Object{args[0], args[1]}, 1), args[2]}, -1)
+		},
+		},
+	},
+	{
+		"pow",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			for i := range args {
+				if _, err := numberArgument("pow", args, i); err != nil {
+					return err
+				}
+			}
+			return Pow(args[0], args[1])
+		},
+		},
+	},
+	{"floor", roundingFunction("floor", math.Floor)},
+	{"ceil", roundingFunction("ceil", math.Ceil)},
+	{"trunc", roundingFunction("trunc", math.Trunc)},
+	{
+		"round",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			if len(args) == 1 {
+				return roundingFunction("round", math.Round).Fn(args...)
+			}
+			x, err := numberArgument("round", args, 0)
+			if err != nil {
+				return err
+			}
+			digits, ok := args[1].(*Integer)
+			if !ok {
+				return integerError("second argument to `round`", args[1])
+			}
+			scale := math.Pow(10, float64(digits.Value))
+			return &Float{Value: math.Round(x*scale) / scale}
+		},
+		},
+	},
+	{
+		"log",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			x, err := numberArgument("log", args, 0)
+			if err != nil {
+				return err
+			}
+			if x < 0 {
+				return newError("log of negative number is not supported")
+			}
+			if len(args) == 1 {
+				return &Float{Value: math.Log(x)}
+			}
+			base, err := numberArgument("log", args, 1)
+			if err != nil {
+				return err
+			}
+			return &Float{Value: math.Log(x) / math.Log(base)}
+		},
+		},
+	},
+	{"log2", mathFunction("log2", math.Log2)},
+	{"log10", mathFunction("log10", math.Log10)},
+	{"exp", mathFunction("exp", math.Exp)},
+	{"sin", mathFunction("sin", math.Sin)},
+	{"cos", mathFunction("cos", math.Cos)},
+	{"tan", mathFunction("tan", math.Tan)},
+	{"asin", mathFunction("asin", math.Asin)},
+	{"acos", mathFunction("acos", math.Acos)},
+	{"atan", mathFunction("atan", math.Atan)},
+	{
+		"atan2",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			y, err := numberArgument("atan2", args, 0)
+			if err != nil {
+				return err
+			}
+			x, err := numberArgument("atan2", args, 1)
+			if err != nil {
+				return err
+			}
+			return &Float{Value: math.Atan2(y, x)}
+		},
+		},
+	},
+	{
+		"random",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			return &Float{Value: hostOf(caller).random().Float64()}
+		},
+		},
+	},
+	{
+		"random_int",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			lo, ok := args[0].(*Integer)
+			if !ok {
+				return integerError("first argument to `random_int`", args[0])
+			}
+			hi, ok := args[1].(*Integer)
+			if !ok {
+				return integerError("second argument to `random_int`", args[1])
+			}
+			if lo.Value > hi.Value {
+				return newError("lower bound of `random_int` is greater than upper bound")
+			}
+			span := uint64(hi.Value-lo.Value) + 1
+			if span == 0 {
+				return NewInteger(int64(hostOf(caller).random().Uint64()))
+			}
+			return NewInteger(lo.Value + int64(hostOf(caller).random().Uint64N(span)))
+		},
+		},
+	},
+	{
+		"shuffle",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			array, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `shuffle` must be ARRAY, got %s",
+					TypeName(args[0]))
+			}
+			elements := slices.Clone(array.Elements)
+			hostOf(caller).random().Shuffle(len(elements), func(i, j int) {
+				elements[i], elements[j] = elements[j], elements[i]
+			})
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"choice",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			array, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument to `choice` must be ARRAY, got %s",
+					TypeName(args[0]))
+			}
+			if len(array.Elements) == 0 {
+				return newError("`choice` of empty array")
+			}
+			return array.Elements[hostOf(caller).random().IntN(len(array.Elements))]
+		},
+		},
+	},
+	{
+		"seed",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			seed, ok := args[0].(*Integer)
+			if !ok {
+				return integerError("argument to `seed`", args[0])
+			}
+			hostOf(caller).seed(seed.Value)
+			return NULL
+		},
+		},
+	},
+	{
+		"trim",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			strs, err := stringArguments("trim", args, len(args))
+			if err != nil {
+				return err
+			}
+			if len(strs) == 1 {
+				return &String{Value: strings.TrimSpace(strs[0])}
+			}
+			return &String{Value: strings.Trim(strs[0], strs[1])}
+		},
+		},
+	},
+	{
+		"trim_left",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			strs, err := stringArguments("trim_left", args, len(args))
+			if err != nil {
+				return err
+			}
+			if len(strs) == 1 {
+				return &String{Value: strings.TrimLeftFunc(strs[0], unicode.IsSpace)}
+			}
+			return &String{Value: strings.TrimLeft(strs[0], strs[1])}
+		},
+		},
+	},
+	{
+		"trim_right",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			strs, err := stringArguments("trim_right", args, len(args))
+			if err != nil {
+				return err
+			}
+			if len(strs) == 1 {
+				return &String{Value: strings.TrimRightFunc(strs[0], unicode.IsSpace)}
+			}
+			return &String{Value: strings.TrimRight(strs[0], strs[1])}
+		},
+		},
+	},
+	{
+		"contains",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArguments("contains", args, 2)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(strs[0], strs[1]) {
+				return TRUE
+			}
+			return FALSE
+		},
+		},
+	},
+	{
+		"starts_with",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArguments("starts_with", args, 2)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(strs[0], strs[1]) {
+				return TRUE
+			}
+			return FALSE
+		},
+		},
+	},
+	{
+		"ends_with",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArguments("ends_with", args, 2)
+			if err != nil {
+				return err
+			}
+			if strings.HasSuffix(strs[0], strs[1]) {
+				return TRUE
+			}
+			return FALSE
+		},
+		},
+	},
+	{
+		"index_of",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArguments("index_of", args, 2)
+			if err != nil {
+				return err
+			}
+			index := strings.Index(strs[0], strs[1])
+			if index < 0 {
+				return NewInteger(-1)
+			}
+			return NewInteger(int64(StringLength(strs[0][:index])))
+		},
+		},
+	},
+	{
+		"last_index_of",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArguments("last_index_of", args, 2)
+			if err != nil {
+				return err
+			}
+			index := strings.LastIndex(strs[0], strs[1])
+			if index < 0 {
+				return NewInteger(-1)
+			}
+			return NewInteger(int64(StringLength(strs[0][:index])))
+		},
+		},
+	},
+	{
+		"repeat",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			str, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `repeat` must be STRING, got %s",
+					TypeName(args[0]))
+			}
+			count, ok := args[1].(*Integer)
+			if !ok {
+				return integerError("second argument to `repeat`", args[1])
+			}
+			if count.Value < 0 {
+				return newError("negative count passed to `repeat`: %d", count.Value)
+			}
+			result, err := repeatString("repeat", str.Value, count.Value)
+			if err != nil {
+				return err
+			}
+			return &String{Value: result}
+		},
+		},
+	},
+	{
+		"pad_left",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, padding, err := padArguments("pad_left", args)
+			if err != nil {
+				return err
+			}
+			return &String{Value: padding + str}
+		},
+		},
+	},
+	{
+		"pad_right",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, padding, err := padArguments("pad_right", args)
+			if err != nil {
+				return err
+			}
+			return &String{Value: str + padding}
+		},
+		},
+	},
+	{
+		"replace_all",
+		&Builtin{Fn: func(args ...Object) Object {
+			strs, err := stringArguments("replace_all", args, 3)
+			if err != nil {
+				return err
+			}
+			return &String{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+		},
+		},
+	},
+	{
+		"ord",
+		&Builtin{Fn: func(args ...Object) Object {
+			str, err := stringArgument("ord", args)
+			if err != nil {
+				return err
+			}
+			if StringLength(str) != 1 {
+				return newError("argument to `ord` must be a single character, got %d characters",
+					StringLength(str))
+			}
+			r, _ := utf8.DecodeRuneInString(str)
+			return NewInteger(int64(r))
+		},
+		},
+	},
+	{
+		"chr",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			code, ok := args[0].(*Integer)
+			if !ok {
+				return integerError("argument to `chr`", args[0])
+			}
+			if code.Value < 0 || code.Value > unicode.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+				return newError("invalid code point: %d", code.Value)
+			}
+			return &String{Value: string(rune(code.Value))}
+		},
+		},
+	},
+	{
+		"format",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want at least 1")
+			}
+			format, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `format` must be STRING, got %s",
+					TypeName(args[0]))
+			}
+			result, err := Format(format.Value, args[1:])
+			if err != nil {
+				return newError("format error: %s", err)
+			}
+			return &String{Value: result}
+		},
+		},
+	},
+	{
+		"keys",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			hash, err := hashArgument("keys", args, 0)
+			if err != nil {
+				return err
+			}
+			return hashElements(hash, func(pair HashPair) Object { return pair.Key })
+		},
+		},
+	},
+	{
+		"values",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			hash, err := hashArgument("values", args, 0)
+			if err != nil {
+				return err
+			}
+			return hashElements(hash, func(pair HashPair) Object { return pair.Value })
+		},
+		},
+	},
+	{
+		"entries",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			hash, err := hashArgument("entries", args, 0)
+			if err != nil {
+				return err
+			}
+			return hashElements(hash, func(pair HashPair) Object {
+				return &Array{Elements: []Object{pair.Key, pair.Value}}
+			})
+		},
+		},
+	},
+	{
+		"has",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			hash, err := hashArgument("has", args, 0)
+			if err != nil {
+				return err
+			}
+			key, err := hashKeyArgument(args[1])
+			if err != nil {
+				return err
+			}
+			if _, found := hash.Get(key); found {
+				return TRUE
+			}
+			return FALSE
+		},
+		},
+	},
+	{
+		"delete",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			hash, err := hashArgument("delete", args, 0)
+			if err != nil {
+				return err
+			}
+			key, err := hashKeyArgument(args[1])
+			if err != nil {
+				return err
+			}
+			return hash.Delete(key)
+		},
+		},
+	},
+	{
+		"merge",
+		mergeFunction("merge", false),
+	},
+	{
+		"deep_merge",
+		mergeFunction("deep_merge", true),
+	},
+	{
+		"from_entries",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			iterator, err := iterableArgument("from_entries", "argument", args[0])
+			if err != nil {
+				return err
+			}
+			return hashFromEntries(iterator)
+		},
+		},
+	},
+	{
+		"get",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2 or 3",
+					len(args))
+			}
+			hash, err := hashArgument("get", args, 0)
+			if err != nil {
+				return err
+			}
+			key, err := hashKeyArgument(args[1])
+			if err != nil {
+				return err
+			}
+			if pair, ok := hash.Get(key); ok {
+				return pair.Value
+			}
+			if len(args) == 3 {
+				return args[2]
+			}
+			return NULL
+		},
+		},
+	},
+	{
+		"type",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			return &String{Value: string(ValueType(args[0]))}
+		},
+		},
+	},
+	{
+		"is_int",
+		typePredicate(INTEGER_OBJ),
+	},
+	{
+		"is_float",
+		typePredicate(FLOAT_OBJ),
+	},
+	{
+		"is_number",
+		typePredicate(INTEGER_OBJ, FLOAT_OBJ),
+	},
+	{
+		"is_string",
+		typePredicate(STRING_OBJ),
+	},
+	{
+		"is_bool",
+		typePredicate(BOOLEAN_OBJ),
+	},
+	{
+		"is_null",
+		typePredicate(NULL_OBJ),
+	},
+	{
+		"is_array",
+		typePredicate(ARRAY_OBJ),
+	},
+	{
+		"is_hash",
+		typePredicate(HASH_OBJ),
+	},
+	{
+		"is_set",
+		typePredicate(SET_OBJ),
+	},
+	{
+		"is_bytes",
+		typePredicate(BYTES_OBJ),
+	},
+	{
+		"is_function",
+		typePredicate(FUNCTION_OBJ, BUILTIN_OBJ, BOUND_METHOD_OBJ),
+	},
+	{
+		"int",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			base := 10
+			if len(args) == 2 {
+				if _, ok := args[0].(*String); !ok {
+					return newError("first argument to `int` must be STRING when a base is given, got %s",
+						TypeName(args[0]))
+				}
+				b, ok := args[1].(*Integer)
+				if !ok {
+					return integerError("second argument to `int`", args[1])
+				}
+				if b.Value < 2 || b.Value > 36 {
+					return newError("invalid base: %d", b.Value)
+				}
+				base = int(b.Value)
+			}
+			result, err := ToInteger(args[0], base)
+			if err != nil {
+				return newError("%s", err)
+			}
+			return result
+		},
+		},
+	},
+	{
+		"float",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			result, err := ToFloat(args[0])
+			if err != nil {
+				return newError("%s", err)
+			}
+			return result
+		},
+		},
+	},
+	{
+		"str",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if str, ok := args[0].(*String); ok {
+				return str
+			}
+			return &String{Value: args[0].Inspect()}
+		},
+		},
+	},
+	{
+		"bool",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			result, err := ToBoolean(args[0])
+			if err != nil {
+				return newError("%s", err)
+			}
+			return result
+		},
+		},
+	},
+	{
+		"repr",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			return &String{Value: Repr(args[0])}
+		},
+		},
+	},
+	{
+		"read_line",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			line, err := readLine(hostOf(caller).input())
+			if err == io.EOF {
+				return NULL
+			}
+			if err != nil {
+				return newError("%s", err)
+			}
+			return &String{Value: line}
+		},
+		},
+	},
+	{
+		"read_all",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			input := hostOf(caller).input()
+			if _, err := input.Peek(1); err == io.EOF {
+				return NULL
+			}
+			content, err := io.ReadAll(input)
+			if err != nil {
+				return newError("%s", err)
+			}
+			return &String{Value: string(content)}
+		},
+		},
+	},
+	{
+		"lines",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			return lineGenerator(hostOf(caller).input(), func() {})
+		},
+		},
+	},
+	{
+		"args",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			hostArgs := hostOf(caller).args()
+			elements := make([]Object, len(hostArgs))
+			for i, arg := range hostArgs {
+				elements[i] = &String{Value: arg}
+			}
+			return &Array{Elements: elements}
+		},
+		},
+	},
+	{
+		"env",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			name, err := stringArgument("env", args)
+			if err != nil {
+				return err
+			}
+			value, ok := hostOf(caller).lookupEnv(name)
+			if !ok {
+				return NULL
+			}
+			return &String{Value: value}
+		},
+		},
+	},
+	{
+		"env_all",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+			return hostOf(caller).environment()
+		},
+		},
+	},
+	{
+		"exit",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments. got=%d, want=0 or 1",
+					len(args))
+			}
+			if len(args) == 0 {
+				return NewExit(0)
+			}
+			code, ok := args[0].(*Integer)
+			if !ok {
+				return integerError("argument to `exit`", args[0])
+			}
+			return NewExit(int(code.Value))
+		},
+		},
+	},
+	{
+		"eputs",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) Object {
+			stderr := hostOf(caller).stderr()
+			for _, arg := range args {
+				fmt.Fprintln(stderr, arg.Inspect())
+			}
+			return nil
+		},
+		},
+	},
+	{
+		"csv_parse",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			text, ok := args[0].(*String)
+			if !ok {
+				return newError("first argument to `csv_parse` must be STRING, got %s",
+					TypeName(args[0]))
+			}
+			options := csvOptions{delimiter: ',', infer: true}
+			if len(args) == 2 {
+				var err *Error
+				options, err = csvOptionsArgument("csv_parse", args[1],
+					[]string{"delimiter", "header", "infer", "lazy_quotes"}, options)
+				if err != nil {
+					return err
+				}
+			}
+
+			result, err := parseCSV(text.Value, options)
+			if err != nil {
+				return newError("invalid CSV: %s", err)
+			}
+			return result
+		},
+		},
+	},
+	{
+		"csv_stringify",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			rows, ok := args[0].(*Array)
+			if !ok {
+				return newError("first argument to `csv_stringify` must be ARRAY, got %s",
+					TypeName(args[0]))
+			}
+			options := csvOptions{delimiter: ',', header: true}
+			if len(args) == 2 {
+				var err *Error
+				options, err = csvOptionsArgument("csv_stringify", args[1],
+					[]string{"delimiter", "header"}, options)
+				if err != nil {
+					return err
+				}
+			}
+
+			records, err := csvRecords(rows.Elements, options)
+			if err != nil {
+				return newError("CSV stringify error: %s", err)
+			}
+			result, err := stringifyCSV(records, options.delimiter)
+			if err != nil {
+				return newError("CSV stringify error: %s", err)
+			}
+			return &String{Value: result}
+		},
+		},
+	},
+}
+
+// TypeName is the type of obj for error messages, allowing for nil. Big
+// integers are an implementation detail and are reported as INTEGER.
+func TypeName(obj Object) ObjectType {
+	switch obj.(type) {
+	case nil:
+		return "nil"
+	case *BigInt:
+		return INTEGER_OBJ
+	default:
+		return obj.Type()
+	}
+}
+
+// integerError reports that arg, described by what, is not an integer of
+// 64 bits. Big integers have the right type but are too large to be used.
+func integerError(what string, arg Object) *Error {
+	if _, ok := arg.(*BigInt); ok {
+		return newError("%s: integer out of range", what)
+	}
+	return newError("%s must be INTEGER, got %s", what, TypeName(arg))
+}
+
+// locationArgument resolves a time zone name such as "UTC", "Local" or
+// "Europe/Berlin" passed to the builtin called name.
+func locationArgument(name string, arg Object) (*time.Location, *Error) {
+	zone, ok := arg.(*String)
+	if !ok {
+		return nil, newError("time zone argument to `%s` must be STRING, got %s",
+			name, TypeName(arg))
+	}
+	location, err := time.LoadLocation(zone.Value)
+	if err != nil {
+		return nil, newError("unknown time zone: %s", zone.Value)
+	}
+	return location, nil
+}
+
+// bytesArgument checks that the builtin called name got a single BYTES or
+// STRING argument and returns its bytes, using UTF-8 for a string.
+func bytesArgument(name string, args []Object) ([]byte, *Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	switch arg := args[0].(type) {
+	case *Bytes:
+		return arg.Value, nil
+	case *String:
+		return []byte(arg.Value), nil
+	case nil:
+		return nil, newError("argument to `%s` cannot be nil", name)
+	default:
+		return nil, newError("argument to `%s` must be BYTES or STRING, got %s",
+			name, TypeName(args[0]))
+	}
+}
+
+// regexArguments checks the regex and text arguments of the builtin called
+// name.
+func regexArguments(name string, args []Object) (*Regex, string, *Error) {
+	if len(args) != 2 {
+		return nil, "", newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	regex, ok := args[0].(*Regex)
+	if !ok {
+		return nil, "", newError("first argument to `%s` must be REGEX, got %s",
+			name, TypeName(args[0]))
+	}
+	text, ok := args[1].(*String)
+	if !ok {
+		return nil, "", newError("second argument to `%s` must be STRING, got %s",
+			name, TypeName(args[1]))
+	}
+	return regex, text.Value, nil
+}
+
+// stringArguments checks that the builtin called name got want arguments
+// that are all strings.
+func stringArguments(name string, args []Object, want int) ([]string, *Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments. got=%d, want=%d",
+			len(args), want)
+	}
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		str, ok := arg.(*String)
+		if !ok {
+			return nil, newError("%s to `%s` must be STRING, got %s",
+				argumentName(args, i), name, TypeName(arg))
+		}
+		strs[i] = str.Value
+	}
+	return strs, nil
+}
+
+// padArguments checks the string, width and optional padding character
+// of pad_left and pad_right and returns the string together with the
+// padding that brings it to the width.
+func padArguments(name string, args []Object) (string, string, *Error) {
+	if len(args) != 2 && len(args) != 3 {
+		return "", "", newError("wrong number of arguments. got=%d, want=2 or 3",
+			len(args))
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return "", "", newError("first argument to `%s` must be STRING, got %s",
+			name, TypeName(args[0]))
+	}
+	width, ok := args[1].(*Integer)
+	if !ok {
+		return "", "", integerError(fmt.Sprintf("second argument to `%s`", name), args[1])
+	}
+	pad := " "
+	if len(args) == 3 {
+		padArg, ok := args[2].(*String)
+		if !ok || StringLength(padArg.Value) != 1 {
+			return "", "", newError("third argument to `%s` must be a single character, got %s",
+				name, args[2].Inspect())
+		}
+		pad = padArg.Value
+	}
+
+	missing := width.Value - int64(StringLength(str.Value))
+	if missing <= 0 {
+		return str.Value, "", nil
+	}
+	padding, err := repeatString(name, pad, missing)
+	if err != nil {
+		return "", "", err
+	}
+	return str.Value, padding, nil
+}
+
+// maxStringSize is the largest string in bytes that repeat and the padding
+// builtins build, so that a huge count is an error rather than a crash.
+const maxStringSize = 1 << 28
+
+// repeatString returns count copies of s for the builtin called name.
+func repeatString(name, s string, count int64) (string, *Error) {
+	if len(s) > 0 && count > maxStringSize/int64(len(s)) {
+		return "", newError("result of `%s` would be longer than %d bytes",
+			name, maxStringSize)
+	}
+	return strings.Repeat(s, int(count)), nil
+}
+
+// stringArgument checks that the builtin called name got a single STRING
+// argument.
+func stringArgument(name string, args []Object) (string, *Error) {
+	if len(args) != 1 {
+		return "", newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if args[0] == nil {
+		return "", newError("argument to `%s` cannot be nil", name)
+	}
+	str, ok := args[0].(*String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s",
+			name, TypeName(args[0]))
+	}
+	return str.Value, nil
+}
+
+// encodingArgument returns the optional encoding name passed as the second
+// argument to encode and decode, defaulting to UTF-8.
+func encodingArgument(name string, args []Object) (string, *Error) {
+	if len(args) < 2 {
+		return "utf-8", nil
+	}
+	if args[1] == nil {
+		return "", newError("second argument to `%s` cannot be nil", name)
+	}
+	encoding, ok := args[1].(*String)
+	if !ok {
+		return "", newError("second argument to `%s` must be STRING, got %s",
+			name, TypeName(args[1]))
+	}
+	return encoding.Value, nil
+}
+
+// setArguments checks that the builtin called name got exactly two sets.
+func setArguments(name string, args []Object) (*Set, *Set, *Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	sets := make([]*Set, 2)
+	for i, arg := range args {
+		set, ok := arg.(*Set)
+		if !ok {
+			return nil, nil, newError("arguments to `%s` must be SET, got %s",
+				name, TypeName(arg))
+		}
+		sets[i] = set
+	}
+	return sets[0], sets[1], nil
+}
+
+// jsonFloat writes a float the way Inspect prints it. JSON has no NaN or
+// infinities, so those are an error rather than silently becoming null.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return nil, fmt.Errorf("%s is not representable in JSON", FormatFloat(float64(f)))
+	}
+	return []byte(FormatFloat(float64(f))), nil
+}
+
+// convertGoValueToMonkeyObject converts Go interface{} to Monkey Object
+func convertGoValueToMonkeyObject(value interface{}) Object {
+	switch v := value.(type) {
+	case nil:
+		return NULL
+	case bool:
+		if v {
+			return TRUE
+		}
+		return FALSE
+	case float64:
+		// JSON numbers are always float64, but check if it's actually an integer
+		if v == float64(int64(v)) {
+			return NewInteger(int64(v))
+		}
+		return &Float{Value: v}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return NewInteger(i)
+		}
+		if i, ok := new(big.Int).SetString(v.String(), 10); ok {
+			return NewBigInt(i)
+		}
+		f, _ := v.Float64()
+		return convertGoValueToMonkeyObject(f)
+	case string:
+		return &String{Value: v}
+	case []interface{}:
+		elements := make([]Object, len(v))
+		for i, elem := range v {
+			elements[i] = convertGoValueToMonkeyObject(elem)
+		}
+		return &Array{Elements: elements}
+	case map[string]interface{}:
+		// Go maps are unordered, so keys are added in sorted order
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		hash := NewHash()
+		for _, key := range keys {
+			keyObj := &String{Value: key}
+			valueObj := convertGoValueToMonkeyObject(v[key])
+			hash.Set(keyObj.HashKey(), HashPair{Key: keyObj, Value: valueObj})
+		}
+		return hash
+	default:
+		return NULL
+	}
+}
+
+// convertMonkeyObjectToGoValue converts Monkey Object to Go interface{}
+func convertMonkeyObjectToGoValue(obj Object) (interface{}, bool) {
+	switch o := obj.(type) {
+	case *Integer:
+		return o.Value, true
+	case *BigInt:
+		return json.Number(o.Value.String()), true
+	case *Float:
+		return jsonFloat(o.Value), true
+	case *Boolean:
+		return o.Value, true
+	case *String:
+		return o.Value, true
+	case *Bytes:
+		return o.Value, true
+	case *Time:
+		return o.Value.Format(time.RFC3339Nano), true
+	case *Duration:
+		return o.Value.String(), true
+	case *Array:
+		result := make([]interface{}, len(o.Elements))
+		for i, elem := range o.Elements {
+			val, ok := convertMonkeyObjectToGoValue(elem)
+			if !ok {
+				return nil, false
+			}
+			result[i] = val
+		}
+		return result, true
+	case *Hash:
+		result := jsonObject{}
+		for _, pair := range o.OrderedPairs() {
+			if keyStr, ok := pair.Key.(*String); ok {
+				val, ok := convertMonkeyObjectToGoValue(pair.Value)
+				if !ok {
+					return nil, false
+				}
+				result.keys = append(result.keys, keyStr.Value)
+				result.values = append(result.values, val)
+			}
+		}
+		return result, true
+	case *Set:
+		result := make([]interface{}, 0, o.Len())
+		for _, elem := range o.Values() {
+			val, ok := convertMonkeyObjectToGoValue(elem)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, val)
+		}
+		return result, true
+	case *Null:
+		return nil, true
+	default:
+		return nil, false
+	}
+}
+
+// decodeJSON reads one JSON value from decoder. Objects are read token by
+// token so that the keys of the resulting hash keep their source order.
+func decodeJSON(decoder *json.Decoder) (Object, error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('['):
+		elements := []Object{}
+		for decoder.More() {
+			element, err := decodeJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return &Array{Elements: elements}, nil
+	case json.Delim('{'):
+		hash := NewHash()
+		for decoder.More() {
+			keyTok, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: keyTok.(string)}
+			value, err := decodeJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key.HashKey(), HashPair{Key: key, Value: value})
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return hash, nil
+	default:
+		return convertGoValueToMonkeyObject(tok), nil
+	}
+}
+
+// jsonObject is a JSON object whose keys are written in the order of the
+// hash it was converted from.
+type jsonObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+
+	out.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		keyBytes, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueBytes, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		out.Write(keyBytes)
+		out.WriteByte(':')
+		out.Write(valueBytes)
+	}
+	out.WriteByte('}')
+
+	return out.Bytes(), nil
 }
 
 func newError(format string, a ...interface{}) *Error {
@@ -0,0 +1,148 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Bytes is an immutable sequence of raw bytes. Unlike a String it makes no
+// assumption about encoding; Encode and Decode convert between the two.
+type Bytes struct {
+	Value []byte
+}
+
+func (b *Bytes) Type() ObjectType { return BYTES_OBJ }
+
+// Inspect prints b in the b"..." literal syntax, escaping every byte that
+// is not printable ASCII.
+func (b *Bytes) Inspect() string {
+	var out bytes.Buffer
+
+	out.WriteString(`b"`)
+	for _, c := range b.Value {
+		switch {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c == '\n':
+			out.WriteString(`\n`)
+		case c == '\t':
+			out.WriteString(`\t`)
+		case c == '\r':
+			out.WriteString(`\r`)
+		case c >= 0x20 && c < 0x7f:
+			out.WriteByte(c)
+		default:
+			fmt.Fprintf(&out, `\x%02x`, c)
+		}
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
+
+func (b *Bytes) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value)
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// normalizeEncoding maps the accepted spellings of an encoding name to one
+// of "utf-8", "ascii", "latin1", "utf-16le" and "utf-16be".
+func normalizeEncoding(name string) (string, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "_", "-")) {
+	case "utf-8", "utf8":
+		return "utf-8", nil
+	case "ascii", "us-ascii":
+		return "ascii", nil
+	case "latin1", "latin-1", "iso-8859-1":
+		return "latin1", nil
+	case "utf-16le", "utf16le":
+		return "utf-16le", nil
+	case "utf-16be", "utf16be":
+		return "utf-16be", nil
+	default:
+		return "", fmt.Errorf("unknown encoding: %s", name)
+	}
+}
+
+// Encode converts str to bytes in the named encoding. It fails if str
+// contains a character the encoding cannot represent.
+func Encode(str, encoding string) ([]byte, error) {
+	encoding, err := normalizeEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	switch encoding {
+	case "utf-8":
+		return []byte(str), nil
+	case "ascii", "latin1":
+		limit := rune(0x7f)
+		if encoding == "latin1" {
+			limit = 0xff
+		}
+		result := make([]byte, 0, len(str))
+		for _, r := range str {
+			if r > limit {
+				return nil, fmt.Errorf("cannot encode %q as %s", r, encoding)
+			}
+			result = append(result, byte(r))
+		}
+		return result, nil
+	default:
+		units := utf16.Encode([]rune(str))
+		result := make([]byte, 0, 2*len(units))
+		for _, u := range units {
+			if encoding == "utf-16le" {
+				result = append(result, byte(u), byte(u>>8))
+			} else {
+				result = append(result, byte(u>>8), byte(u))
+			}
+		}
+		return result, nil
+	}
+}
+
+// Decode converts data in the named encoding to a string. It fails if data
+// is not valid in that encoding.
+func Decode(data []byte, encoding string) (string, error) {
+	encoding, err := normalizeEncoding(encoding)
+	if err != nil {
+		return "", err
+	}
+
+	switch encoding {
+	case "utf-8":
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("invalid utf-8 data")
+		}
+		return string(data), nil
+	case "ascii", "latin1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			if encoding == "ascii" && b > 0x7f {
+				return "", fmt.Errorf("invalid ascii byte 0x%02x at %d", b, i)
+			}
+			runes[i] = rune(b)
+		}
+		return string(runes), nil
+	default:
+		if len(data)%2 != 0 {
+			return "", fmt.Errorf("invalid %s data: odd length", encoding)
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if encoding == "utf-16le" {
+				units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+			} else {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			}
+		}
+		return string(utf16.Decode(units)), nil
+	}
+}
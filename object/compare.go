@@ -0,0 +1,219 @@
+package object
+
+import (
+	"bytes"
+	"cmp"
+	"strings"
+)
+
+// Compare applies one of the comparison operators ==, !=, <, >, <= and >=
+// and is shared by both engines so that they agree on every result.
+// Numbers compare by value across Integer, BigInt and Float, and strings
+// and bytes compare lexicographically. Times compare as instants and
+// durations by length. ok is false if operator is not a comparison
+// or the operands cannot be ordered.
+func Compare(operator string, left, right Object) (result bool, ok bool) {
+	switch {
+	case IsInteger(left) && IsInteger(right):
+		return compareOrdered(operator, CompareIntegers(left, right))
+	case isNumber(left) && isNumber(right):
+		return compareFloats(operator, toFloat(left), toFloat(right))
+	case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
+		return compareOrdered(operator, strings.Compare(left.(*String).Value, right.(*String).Value))
+	case left.Type() == BYTES_OBJ && right.Type() == BYTES_OBJ:
+		return compareOrdered(operator, bytes.Compare(left.(*Bytes).Value, right.(*Bytes).Value))
+	}
+	if result, ok := compareTimes(left, right); ok {
+		return compareOrdered(operator, result)
+	}
+
+	switch operator {
+	case "==":
+		return Equals(left, right), true
+	case "!=":
+		return !Equals(left, right), true
+	default:
+		return false, false
+	}
+}
+
+// Equals reports whether two values are equal. Numbers, strings, bytes,
+// times and durations compare by value, arrays, hashes and sets compare their contents recursively,
+// and everything else compares by identity.
+func Equals(left, right Object) bool {
+	switch {
+	case isNumber(left) && isNumber(right),
+		left.Type() == STRING_OBJ && right.Type() == STRING_OBJ,
+		left.Type() == BYTES_OBJ && right.Type() == BYTES_OBJ,
+		IsTimeValue(left) && left.Type() == right.Type():
+		result, _ := Compare("==", left, right)
+		return result
+	case left == right:
+		return true
+	}
+
+	switch left := left.(type) {
+	case *Array:
+		right, ok := right.(*Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		for i, el := range left.Elements {
+			if !Equals(el, right.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		right, ok := right.(*Hash)
+		if !ok || left.Len() != right.Len() {
+			return false
+		}
+		for _, pair := range left.OrderedPairs() {
+			key, _ := HashKeyOf(pair.Key)
+			other, ok := right.Get(key)
+			if !ok || !Equals(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		right, ok := right.(*Set)
+		if !ok || left.Len() != right.Len() {
+			return false
+		}
+		for key := range left.Elements {
+			if _, ok := right.Elements[key]; !ok {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// Order is the total order used for sorting. It returns -1, 0 or +1
+// depending on whether left sorts before, together with or after right.
+// Values of different kinds sort by kind: null, booleans, numbers,
+// strings, bytes, times, durations, arrays and then everything else by
+// type name. Within a kind false sorts before true, numbers sort by value
+// with NaN first, arrays sort element by element, and values of other
+// types are equal.
+func Order(left, right Object) int {
+	if rank := cmp.Compare(orderRank(left), orderRank(right)); rank != 0 {
+		return rank
+	}
+
+	switch {
+	case IsInteger(left) && IsInteger(right):
+		return CompareIntegers(left, right)
+	case isNumber(left):
+		return cmp.Compare(toFloat(left), toFloat(right))
+	}
+	if result, ok := compareTimes(left, right); ok {
+		return result
+	}
+
+	switch left := left.(type) {
+	case *Boolean:
+		return cmp.Compare(boolRank(left.Value), boolRank(right.(*Boolean).Value))
+	case *String:
+		return strings.Compare(left.Value, right.(*String).Value)
+	case *Bytes:
+		return bytes.Compare(left.Value, right.(*Bytes).Value)
+	case *Array:
+		other := right.(*Array).Elements
+		for i, el := range left.Elements {
+			if i >= len(other) {
+				return 1
+			}
+			if result := Order(el, other[i]); result != 0 {
+				return result
+			}
+		}
+		return cmp.Compare(len(left.Elements), len(other))
+	default:
+		return strings.Compare(string(ValueType(left)), string(ValueType(right)))
+	}
+}
+
+var orderRanks = map[ObjectType]int{
+	NULL_OBJ:     0,
+	BOOLEAN_OBJ:  1,
+	INTEGER_OBJ:  2,
+	BIGINT_OBJ:   2,
+	FLOAT_OBJ:    2,
+	STRING_OBJ:   3,
+	BYTES_OBJ:    4,
+	TIME_OBJ:     5,
+	DURATION_OBJ: 6,
+	ARRAY_OBJ:    7,
+}
+
+func orderRank(obj Object) int {
+	if rank, ok := orderRanks[obj.Type()]; ok {
+		return rank
+	}
+	return len(orderRanks)
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func isNumber(obj Object) bool {
+	return IsInteger(obj) || obj.Type() == FLOAT_OBJ
+}
+
+func toFloat(obj Object) float64 {
+	if f, ok := obj.(*Float); ok {
+		return f.Value
+	}
+	return IntegerToFloat(obj)
+}
+
+// compareOrdered applies operator to the result cmp of a three-way
+// comparison.
+func compareOrdered(operator string, cmp int) (bool, bool) {
+	switch operator {
+	case "==":
+		return cmp == 0, true
+	case "!=":
+		return cmp != 0, true
+	case "<":
+		return cmp < 0, true
+	case ">":
+		return cmp > 0, true
+	case "<=":
+		return cmp <= 0, true
+	case ">=":
+		return cmp >= 0, true
+	default:
+		return false, false
+	}
+}
+
+// compareFloats compares directly rather than through a three-way result
+// so that every ordering involving NaN is false.
+func compareFloats(operator string, left, right float64) (bool, bool) {
+	switch operator {
+	case "==":
+		return left == right, true
+	case "!=":
+		return left != right, true
+	case "<":
+		return left < right, true
+	case ">":
+		return left > right, true
+	case "<=":
+		return left <= right, true
+	case ">=":
+		return left >= right, true
+	default:
+		return false, false
+	}
+}
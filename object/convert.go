@@ -0,0 +1,154 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the type the type builtin reports for obj. It is obj.Type()
+// except that the representations an engine picks for the same kind of
+// value are reported alike: big integers are INTEGER and compiled closures
+// are FUNCTION.
+func ValueType(obj Object) ObjectType {
+	switch obj.(type) {
+	case *BigInt:
+		return INTEGER_OBJ
+	case *Closure, *CompiledFunction:
+		return FUNCTION_OBJ
+	default:
+		return obj.Type()
+	}
+}
+
+// typePredicate returns a builtin that reports whether its argument is of
+// one of types, as named by ValueType.
+func typePredicate(types ...ObjectType) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1",
+				len(args))
+		}
+		for _, t := range types {
+			if ValueType(args[0]) == t {
+				return TRUE
+			}
+		}
+		return FALSE
+	},
+	}
+}
+
+// ToInteger converts obj to an integer. Floats are truncated towards zero
+// and strings must hold nothing but an optionally signed integer in base.
+func ToInteger(obj Object, base int) (Object, error) {
+	switch obj := obj.(type) {
+	case *Integer, *BigInt:
+		return obj, nil
+	case *Float:
+		return FloatToInteger(math.Trunc(obj.Value))
+	case *String:
+		value, ok := new(big.Int).SetString(obj.Value, base)
+		if !ok {
+			return nil, fmt.Errorf("cannot parse %s as INTEGER", strconv.Quote(obj.Value))
+		}
+		return NewBigInt(value), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to INTEGER", TypeName(obj))
+	}
+}
+
+// ToFloat converts obj to a float. Strings must hold nothing but a decimal
+// or scientific number, or one of Inf, +Inf, -Inf and NaN, spelled exactly
+// the way FormatFloat writes them. The hexadecimal floats, underscores and
+// other spellings of infinity Go accepts are rejected.
+func ToFloat(obj Object) (Object, error) {
+	switch obj := obj.(type) {
+	case *Integer, *BigInt:
+		return &Float{Value: IntegerToFloat(obj)}, nil
+	case *Float:
+		return obj, nil
+	case *String:
+		switch obj.Value {
+		case "Inf", "+Inf":
+			return &Float{Value: math.Inf(1)}, nil
+		case "-Inf":
+			return &Float{Value: math.Inf(-1)}, nil
+		case "NaN":
+			return &Float{Value: math.NaN()}, nil
+		}
+		value, err := strconv.ParseFloat(obj.Value, 64)
+		if err != nil || strings.ContainsAny(obj.Value, "xX_iInN") {
+			return nil, fmt.Errorf("cannot parse %s as FLOAT", strconv.Quote(obj.Value))
+		}
+		return &Float{Value: value}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to FLOAT", TypeName(obj))
+	}
+}
+
+// ToBoolean converts obj to a boolean. Strings must be "true" or "false";
+// any other value converts to its truthiness.
+func ToBoolean(obj Object) (Object, error) {
+	str, ok := obj.(*String)
+	if !ok {
+		if IsTruthy(obj) {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	}
+	switch str.Value {
+	case "true":
+		return TRUE, nil
+	case "false":
+		return FALSE, nil
+	default:
+		return nil, fmt.Errorf("cannot parse %s as BOOLEAN", strconv.Quote(str.Value))
+	}
+}
+
+// Repr returns obj the way Inspect does, except that strings are quoted,
+// also inside arrays, hashes, sets and struct instances, so that "1" and 1
+// can be told apart.
+func Repr(obj Object) string {
+	switch obj := obj.(type) {
+	case *String:
+		return strconv.Quote(obj.Value)
+	case *Array:
+		return "[" + reprJoin(obj.Elements) + "]"
+	case *Set:
+		return "#{" + reprJoin(obj.Values()) + "}"
+	case *Hash:
+		var out bytes.Buffer
+		out.WriteString("{")
+		for i, pair := range obj.OrderedPairs() {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			out.WriteString(Repr(pair.Key))
+			out.WriteString(": ")
+			out.WriteString(Repr(pair.Value))
+		}
+		out.WriteString("}")
+		return out.String()
+	case *Instance:
+		fields := make([]string, len(obj.Fields))
+		for i, name := range obj.Struct.Fields {
+			fields[i] = name + ": " + Repr(obj.Fields[i])
+		}
+		return obj.Struct.Name + "{" + strings.Join(fields, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
+
+func reprJoin(values []Object) string {
+	elements := make([]string, len(values))
+	for i, value := range values {
+		elements[i] = Repr(value)
+	}
+	return strings.Join(elements, ", ")
+}
@@ -0,0 +1,240 @@
+package object
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// csvOptions are the settings csv_parse and csv_stringify take from their
+// optional hash argument, e.g. {"delimiter": ";", "header": true}.
+type csvOptions struct {
+	delimiter  rune
+	header     bool
+	infer      bool
+	lazyQuotes bool
+}
+
+// csvOptionsArgument reads the options hash passed to the builtin called
+// name, which accepts the options listed in allowed.
+func csvOptionsArgument(name string, arg Object, allowed []string, options csvOptions) (csvOptions, *Error) {
+	hash, ok := arg.(*Hash)
+	if !ok {
+		return options, newError("second argument to `%s` must be HASH, got %s",
+			name, TypeName(arg))
+	}
+
+	for _, pair := range hash.OrderedPairs() {
+		key, ok := pair.Key.(*String)
+		if !ok || !slices.Contains(allowed, key.Value) {
+			return options, newError("unknown option for `%s`: %s", name, Repr(pair.Key))
+		}
+
+		if key.Value == "delimiter" {
+			delimiter, ok := pair.Value.(*String)
+			if !ok || utf8.RuneCountInString(delimiter.Value) != 1 {
+				return options, newError("option `delimiter` of `%s` must be a single character, got %s",
+					name, Repr(pair.Value))
+			}
+			options.delimiter, _ = utf8.DecodeRuneInString(delimiter.Value)
+			continue
+		}
+
+		value, ok := pair.Value.(*Boolean)
+		if !ok {
+			return options, newError("option `%s` of `%s` must be BOOLEAN, got %s",
+				key.Value, name, TypeName(pair.Value))
+		}
+		switch key.Value {
+		case "header":
+			options.header = value.Value
+		case "infer":
+			options.infer = value.Value
+		case "lazy_quotes":
+			options.lazyQuotes = value.Value
+		}
+	}
+	return options, nil
+}
+
+// parseCSV reads all records of text. Without a header every record is an
+// array of fields, and records may differ in length; with one every
+// following record is a hash from column name to field, in column order,
+// and must have a field for every column.
+func parseCSV(text string, options csvOptions) (Object, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = options.delimiter
+	reader.LazyQuotes = options.lazyQuotes
+	if !options.header {
+		reader.FieldsPerRecord = -1
+	}
+
+	var columns []*String
+	rows := []Object{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return &Array{Elements: rows}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if options.header && columns == nil {
+			seen := map[string]bool{}
+			for _, name := range record {
+				if seen[name] {
+					return nil, fmt.Errorf("duplicate column %q in header", name)
+				}
+				seen[name] = true
+				columns = append(columns, &String{Value: name})
+			}
+			continue
+		}
+
+		fields := make([]Object, len(record))
+		for i, field := range record {
+			if options.infer {
+				fields[i] = inferCSVField(field)
+			} else {
+				fields[i] = &String{Value: field}
+			}
+		}
+
+		if !options.header {
+			rows = append(rows, &Array{Elements: fields})
+			continue
+		}
+		row := NewHash()
+		for i, column := range columns {
+			row.Set(column.HashKey(), HashPair{Key: column, Value: fields[i]})
+		}
+		rows = append(rows, row)
+	}
+}
+
+// inferCSVField converts a field that looks like a number, a boolean or
+// nothing at all to the value it stands for. Numbers follow the JSON
+// syntax, so that fields such as "007" or "+1" stay strings, and are only
+// converted if they read back exactly as written: "0.10", "1e5", "-0" and
+// "1e400" stay strings rather than lose how they were written or their
+// value.
+func inferCSVField(field string) Object {
+	switch field {
+	case "":
+		return NULL
+	case "true":
+		return TRUE
+	case "false":
+		return FALSE
+	}
+	first, last := field[0], field[len(field)-1]
+	if (first == '-' || isDigit(first)) && isDigit(last) && json.Valid([]byte(field)) {
+		if number := convertGoValueToMonkeyObject(json.Number(field)); number.Inspect() == field {
+			return number
+		}
+	}
+	return &String{Value: field}
+}
+
+// csvRecords converts the rows passed to csv_stringify to records. Rows
+// are either all arrays or all hashes; the columns of hashes are their
+// keys in the order they first occur, and a header naming them comes
+// first if options.header is set.
+func csvRecords(rows []Object, options csvOptions) ([][]string, error) {
+	records := [][]string{}
+	if len(rows) == 0 {
+		return records, nil
+	}
+
+	if _, ok := rows[0].(*Hash); !ok {
+		for _, row := range rows {
+			array, ok := row.(*Array)
+			if !ok {
+				return nil, fmt.Errorf("rows must all be ARRAY or all be HASH, got %s", TypeName(row))
+			}
+			record, err := csvFields(array.Elements)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	var columns []Object
+	seen := map[HashKey]bool{}
+	for _, row := range rows {
+		hash, ok := row.(*Hash)
+		if !ok {
+			return nil, fmt.Errorf("rows must all be ARRAY or all be HASH, got %s", TypeName(row))
+		}
+		for _, pair := range hash.OrderedPairs() {
+			key, _ := HashKeyOf(pair.Key)
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, pair.Key)
+			}
+		}
+	}
+
+	if options.header {
+		header, err := csvFields(columns)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, header)
+	}
+	for _, row := range rows {
+		values := make([]Object, len(columns))
+		for i, column := range columns {
+			key, _ := HashKeyOf(column)
+			if pair, ok := row.(*Hash).Get(key); ok {
+				values[i] = pair.Value
+			} else {
+				values[i] = NULL
+			}
+		}
+		record, err := csvFields(values)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// csvFields converts values to CSV fields. null is an empty field, and
+// collections and functions cannot be written.
+func csvFields(values []Object) ([]string, error) {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		switch value := value.(type) {
+		case *Null:
+			fields[i] = ""
+		case *String:
+			fields[i] = value.Value
+		case *Integer, *BigInt, *Float, *Boolean, *Time, *Duration:
+			fields[i] = value.Inspect()
+		default:
+			return nil, fmt.Errorf("cannot write %s as a CSV field", TypeName(value))
+		}
+	}
+	return fields, nil
+}
+
+// stringifyCSV writes records with delimiter, quoting the fields that need
+// it.
+func stringifyCSV(records [][]string, delimiter rune) (string, error) {
+	var out strings.Builder
+	writer := csv.NewWriter(&out)
+	writer.Comma = delimiter
+	if err := writer.WriteAll(records); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -3,6 +3,12 @@ package object
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	// yield is set on the environment of a running generator body
+	yield func(Object)
+	// host overrides the host installed with SetHost for code running in
+	// this environment and the environments enclosed by it
+	host *Host
 }
 
 func NewEnvironment() *Environment {
@@ -24,10 +30,77 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Set binds name in this environment only, shadowing any binding of the
+// same name in an outer environment.
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
-	if e.outer != nil {
-		e.outer.Set(name, val)
-	}
 	return val
 }
+
+// Assign updates the nearest existing binding of name. It reports false
+// when name is not bound in this environment or any outer one.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// Capture returns the environment for a closure created in e. Like the VM,
+// which keeps only the top-level variables as globals, it shares the
+// outermost environment and copies the bindings of all environments
+// enclosed by it, so that the closure keeps the values they had when it was
+// created and assignments in the closure change its own copy.
+func (e *Environment) Capture() *Environment {
+	if e.outer == nil {
+		return e
+	}
+	var chain []*Environment
+	env := e
+	for ; env.outer != nil; env = env.outer {
+		chain = append(chain, env)
+	}
+	captured := NewEnclosedEnvironment(env)
+	captured.host = e.Host()
+	for i := len(chain) - 1; i >= 0; i-- {
+		for name, val := range chain[i].store {
+			captured.store[name] = val
+		}
+	}
+	return captured
+}
+
+// SetYield installs the function that suspends the generator whose body
+// runs in this environment.
+func (e *Environment) SetYield(yield func(Object)) {
+	e.yield = yield
+}
+
+// Yield returns the yield function of the innermost enclosing generator.
+func (e *Environment) Yield() (func(Object), bool) {
+	for env := e; env != nil; env = env.outer {
+		if env.yield != nil {
+			return env.yield, true
+		}
+	}
+	return nil, false
+}
+
+// SetHost makes builtins called from code in this environment use h.
+func (e *Environment) SetHost(h *Host) {
+	e.host = h
+}
+
+// Host returns the host set on the innermost enclosing environment that
+// has one, or nil.
+func (e *Environment) Host() *Host {
+	for env := e; env != nil; env = env.outer {
+		if env.host != nil {
+			return env.host
+		}
+	}
+	return nil
+}
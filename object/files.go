@@ -0,0 +1,98 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// pathArgument checks the path that is the first argument of the file
+// builtin called name and resolves it within the file roots of the host of
+// caller.
+func pathArgument(caller Caller, name string, args []Object, want int) (string, *Error) {
+	if len(args) != want {
+		return "", newError("wrong number of arguments. got=%d, want=%d",
+			len(args), want)
+	}
+	path, ok := args[0].(*String)
+	if !ok {
+		return "", newError("first argument to `%s` must be STRING, got %s",
+			name, TypeName(args[0]))
+	}
+	resolved, err := hostOf(caller).resolvePath(path.Value)
+	if err != nil {
+		return "", newError("%s", err)
+	}
+	return resolved, nil
+}
+
+// writeFile writes the string or bytes data to the file at path, opened
+// with flag.
+func writeFile(name, path string, data Object, flag int) Object {
+	var content []byte
+	switch data := data.(type) {
+	case *String:
+		content = []byte(data.Value)
+	case *Bytes:
+		content = data.Value
+	default:
+		return newError("second argument to `%s` must be STRING or BYTES, got %s",
+			name, TypeName(data))
+	}
+
+	file, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return newError("%s", err)
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return newError("%s", err)
+	}
+	return NULL
+}
+
+// fileLines returns a generator over the lines of file without their line
+// endings. The file is closed once the last line has been read or the
+// generator is closed; a generator that is dropped halfway keeps the file
+// open until the garbage collector finalizes it.
+func fileLines(file *os.File) *Generator {
+	return lineGenerator(bufio.NewReader(file), func() { file.Close() })
+}
+
+// lineGenerator returns a generator over the lines of reader and calls done
+// once the last line has been read, reading has failed or the generator is
+// closed.
+func lineGenerator(reader *bufio.Reader, done func()) *Generator {
+	finished := false
+	return NewGenerator(func() (Object, bool) {
+		if finished {
+			return nil, false
+		}
+		line, err := readLine(reader)
+		if err != nil {
+			finished = true
+			done()
+			if err != io.EOF {
+				return newError("%s", err), true
+			}
+			return nil, false
+		}
+		return &String{Value: line}, true
+	}, done)
+}
+
+// readLine reads the next line from reader without its line ending. The
+// last line need not end in a newline; io.EOF is returned only once there
+// is no input left.
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
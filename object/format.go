@@ -0,0 +1,119 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format implements the format builtin. Directives follow fmt, with flags,
+// width and precision, and each verb accepts the Monkey values that match
+// it:
+//
+//	%d %b %o %c       integers
+//	%f %F %e %E %g %G numbers
+//	%x %X             integers, strings and bytes
+//	%s %v             any value, as puts would print it
+//	%q                strings
+//	%t                booleans
+//	%%                a literal percent sign
+func Format(format string, args []Object) (string, error) {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && isDigit(format[i]) {
+			i++
+		}
+		if i < len(format) && format[i] == '.' {
+			i++
+			for i < len(format) && isDigit(format[i]) {
+				i++
+			}
+		}
+		if i >= len(format) {
+			return "", fmt.Errorf("incomplete directive %q at end of format", format[start:])
+		}
+		spec, verb := format[start:i+1], format[i]
+
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next >= len(args) {
+			return "", fmt.Errorf("missing argument for %s", spec)
+		}
+		value, err := formatValue(verb, args[next])
+		if err != nil {
+			return "", fmt.Errorf("%s: %s", spec, err)
+		}
+		next++
+		fmt.Fprintf(&out, spec, value)
+	}
+
+	if next < len(args) {
+		return "", fmt.Errorf("too many arguments: format uses %d, got %d", next, len(args))
+	}
+	return out.String(), nil
+}
+
+// formatValue converts obj to the Go value that verb formats.
+func formatValue(verb byte, obj Object) (interface{}, error) {
+	switch verb {
+	case 'd', 'b', 'o', 'c':
+		switch obj := obj.(type) {
+		case *Integer:
+			return obj.Value, nil
+		case *BigInt:
+			if verb != 'c' {
+				return obj.Value, nil
+			}
+			return nil, fmt.Errorf("integer out of range")
+		}
+		return nil, fmt.Errorf("expected INTEGER, got %s", TypeName(obj))
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		if !isNumber(obj) {
+			return nil, fmt.Errorf("expected INTEGER or FLOAT, got %s", TypeName(obj))
+		}
+		return toFloat(obj), nil
+	case 'x', 'X':
+		switch obj := obj.(type) {
+		case *Integer:
+			return obj.Value, nil
+		case *BigInt:
+			return obj.Value, nil
+		case *String:
+			return obj.Value, nil
+		case *Bytes:
+			return obj.Value, nil
+		}
+		return nil, fmt.Errorf("expected INTEGER, STRING or BYTES, got %s", TypeName(obj))
+	case 's', 'v':
+		return obj.Inspect(), nil
+	case 'q':
+		if str, ok := obj.(*String); ok {
+			return str.Value, nil
+		}
+		return nil, fmt.Errorf("expected STRING, got %s", TypeName(obj))
+	case 't':
+		if b, ok := obj.(*Boolean); ok {
+			return b.Value, nil
+		}
+		return nil, fmt.Errorf("expected BOOLEAN, got %s", TypeName(obj))
+	default:
+		return nil, fmt.Errorf("unknown verb %%%c", verb)
+	}
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
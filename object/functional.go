@@ -0,0 +1,96 @@
+package object
+
+// The higher-order builtins such as map and filter accept anything a for-in
+// loop can iterate over and call their function argument through the
+// Caller of the engine that runs them. The first error, whether raised by
+// the function or by a generator being iterated, stops the iteration and
+// becomes the result of the builtin.
+
+// IsTruthy reports whether obj counts as true in a condition: everything
+// except false and null does.
+func IsTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
+
+// IsCallable reports whether obj can be called like a function.
+func IsCallable(obj Object) bool {
+	switch obj.(type) {
+	case *Function, *Closure, *Builtin, *BoundMethod, *StructType:
+		return true
+	default:
+		return false
+	}
+}
+
+// callbackArguments checks the iterable and function arguments of the
+// higher-order builtin called name.
+func callbackArguments(name string, args []Object) (Iterator, Object, *Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	iterator, err := iterableArgument(name, "first argument", args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if !IsCallable(args[1]) {
+		return nil, nil, newError("second argument to `%s` must be FUNCTION, got %s",
+			name, TypeName(args[1]))
+	}
+	return iterator, args[1], nil
+}
+
+// iterableArgument checks the argument of the builtin called name that
+// argument names in error messages, e.g. "first argument".
+func iterableArgument(name, argument string, arg Object) (Iterator, *Error) {
+	if arg == nil {
+		return nil, newError("%s to `%s` cannot be nil", argument, name)
+	}
+	iterator, ok := Iterate(arg)
+	if !ok {
+		return nil, newError("%s to `%s` must be iterable, got %s",
+			argument, name, TypeName(arg))
+	}
+	return iterator, nil
+}
+
+// nextValue returns the next value of iterator, turning an error produced
+// by a generator into the returned *Error.
+func nextValue(iterator Iterator) (Object, bool, *Error) {
+	value, ok := iterator.Next()
+	if !ok {
+		return nil, false, nil
+	}
+	if err, isError := value.(*Error); isError {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// eachResult calls fn with every value of iterator and hands the value and
+// the result of the call to visit until visit returns false.
+func eachResult(caller Caller, iterator Iterator, fn Object, visit func(value, result Object) bool) *Error {
+	for {
+		value, ok, err := nextValue(iterator)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		result := caller.Call(fn, value)
+		if err, isError := result.(*Error); isError {
+			return err
+		}
+		if !visit(value, result) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package object
+
+// The hash builtins never modify the hash they are given: delete and merge
+// return new hashes that share the untouched pairs with their arguments.
+// Keys keep the insertion order of the hash, and keys added by merge go
+// after the existing ones in the order of the later hash.
+
+// hashArgument returns argument i of the builtin called name, which must be
+// a hash.
+func hashArgument(name string, args []Object, i int) (*Hash, *Error) {
+	hash, ok := args[i].(*Hash)
+	if !ok {
+		return nil, newError("%s to `%s` must be HASH, got %s",
+			argumentName(args, i), name, TypeName(args[i]))
+	}
+	return hash, nil
+}
+
+// hashKeyArgument returns the hash key of key, which must be usable as
+// one.
+func hashKeyArgument(key Object) (HashKey, *Error) {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return HashKey{}, newError("unusable as hash key: %s", TypeName(key))
+	}
+	return hashKey, nil
+}
+
+// hashElements returns the keys, the values or the [key, value] entries of
+// hash, depending on element, as an array in insertion order.
+func hashElements(hash *Hash, element func(pair HashPair) Object) *Array {
+	pairs := hash.OrderedPairs()
+	elements := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		elements[i] = element(pair)
+	}
+	return &Array{Elements: elements}
+}
+
+// MergeHashes returns a hash with the pairs of base and then those of
+// other, whose values win when both have a key. With deep set, two values
+// that are both hashes are merged in turn instead of being replaced.
+func MergeHashes(base, other *Hash, deep bool) *Hash {
+	result := base
+	for _, pair := range other.OrderedPairs() {
+		key, _ := HashKeyOf(pair.Key)
+		value := pair.Value
+		if deep {
+			existing, ok := result.Get(key)
+			baseHash, baseIsHash := existing.Value.(*Hash)
+			otherHash, otherIsHash := value.(*Hash)
+			if ok && baseIsHash && otherIsHash {
+				value = MergeHashes(baseHash, otherHash, true)
+			}
+		}
+		result = result.Put(key, HashPair{Key: pair.Key, Value: value})
+	}
+	return result
+}
+
+// mergeFunction returns the builtin that merges its hash arguments from
+// left to right.
+func mergeFunction(name string, deep bool) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) == 0 {
+			return newError("wrong number of arguments. got=0, want at least 1")
+		}
+		result, err := hashArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+		for i := 1; i < len(args); i++ {
+			other, err := hashArgument(name, args, i)
+			if err != nil {
+				return err
+			}
+			result = MergeHashes(result, other, deep)
+		}
+		return result
+	},
+	}
+}
+
+// hashFromEntries builds a hash from the [key, value] arrays produced by
+// iterator. A key that occurs again replaces the earlier value but keeps
+// its place.
+func hashFromEntries(iterator Iterator) Object {
+	hash := NewHash()
+	for {
+		entry, ok, err := nextValue(iterator)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return hash
+		}
+		pair, isArray := entry.(*Array)
+		if !isArray || len(pair.Elements) != 2 {
+			return newError("entries passed to `from_entries` must be [key, value] arrays, got %s",
+				entry.Inspect())
+		}
+		key, keyErr := hashKeyArgument(pair.Elements[0])
+		if keyErr != nil {
+			return keyErr
+		}
+		hash.Set(key, HashPair{Key: pair.Elements[0], Value: pair.Elements[1]})
+	}
+}
@@ -0,0 +1,153 @@
+package object
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Host connects the builtins to the world outside the interpreter. An
+// embedding program or a test replaces parts of it to sandbox scripts or to
+// make them deterministic; fields left nil fall back to the real system.
+//
+// The fields must not be changed by the embedding program once scripts
+// use the host. Scripts themselves change the state behind two of them,
+// the source seeded by seed() and the buffered standard input, and that
+// state is shared by every engine using the host, including all engines
+// without a host of their own. The random source is guarded by a lock, but
+// engines running at the same time must not read the same standard input.
+// Scripts that must not affect each other need hosts of their own.
+type Host struct {
+	// Now returns the current time for now().
+	Now func() time.Time
+	// FileRoots are the directories the file builtins may access, together
+	// with everything below them. Without roots all file access is denied.
+	FileRoots []string
+	// Random is the source of random(), random_int(), shuffle() and
+	// choice(). seed() replaces it with a deterministic one. Without it
+	// the global source of math/rand is used.
+	Random *rand.Rand
+	// Stdin is the standard input of read_line(), read_all() and lines().
+	// It is buffered on first use and must not be replaced afterwards. A
+	// *bufio.Reader is used as it is, so that the embedding program can
+	// share it with the scripts.
+	Stdin io.Reader
+	// Stderr receives the output of eputs().
+	Stderr io.Writer
+	// Args are the command-line arguments returned by args(). Without
+	// them the arguments of the process after the program name are used.
+	Args []string
+	// Env holds the variables seen by env() and env_all(). Without it the
+	// environment of the process is used.
+	Env map[string]string
+
+	// mu guards Random and stdin
+	mu    sync.Mutex
+	stdin *bufio.Reader
+}
+
+var host = &Host{}
+
+// SetHost makes the builtins use h and returns the host that was used
+// before, so that callers can restore it.
+func SetHost(h *Host) *Host {
+	previous := host
+	host = h
+	return previous
+}
+
+// hostOf returns the host of the engine behind caller if it has one of its
+// own, and the host installed with SetHost otherwise.
+func hostOf(caller Caller) *Host {
+	if engine, ok := caller.(interface{ Host() *Host }); ok {
+		if h := engine.Host(); h != nil {
+			return h
+		}
+	}
+	return host
+}
+
+func (h *Host) now() time.Time {
+	if h.Now == nil {
+		return time.Now()
+	}
+	return h.Now()
+}
+
+// input returns the buffered standard input of h. The builtins share it,
+// so that input read ahead by one of them is not lost to the others.
+func (h *Host) input() *bufio.Reader {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stdin == nil {
+		if h.Stdin == nil {
+			h.stdin = bufio.NewReader(os.Stdin)
+		} else {
+			h.stdin = bufio.NewReader(h.Stdin)
+		}
+	}
+	return h.stdin
+}
+
+// resolvePath returns the absolute form of path, with symbolic links
+// resolved, if it lies within one of the file roots of h.
+func (h *Host) resolvePath(path string) (string, error) {
+	if len(h.FileRoots) == 0 {
+		return "", errors.New("file access is not allowed")
+	}
+	resolved, err := resolveExisting(path)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range h.FileRoots {
+		root, err := resolveExisting(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("access denied: %s is outside the allowed directories", path)
+}
+
+// isFileRoot reports whether the resolved path is one of the file roots of
+// h itself rather than something below it.
+func (h *Host) isFileRoot(resolved string) bool {
+	for _, root := range h.FileRoots {
+		if root, err := resolveExisting(root); err == nil && root == resolved {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveExisting makes path absolute and resolves the symbolic links in
+// the part of it that exists, so that a link cannot lead out of a root
+// even when the final elements are yet to be created.
+func resolveExisting(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	missing := ""
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) || filepath.Dir(dir) == dir {
+			return "", err
+		}
+		missing = filepath.Join(filepath.Base(dir), missing)
+	}
+}
@@ -0,0 +1,215 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand/v2"
+)
+
+// BuiltinValues are the predefined names that stand for values rather than
+// functions. The compiler loads them as constants and the evaluator looks
+// them up after the builtin functions.
+var BuiltinValues = []struct {
+	Name  string
+	Value Object
+}{
+	{"PI", &Float{Value: math.Pi}},
+	{"E", &Float{Value: math.E}},
+	{"INF", &Float{Value: math.Inf(1)}},
+	{"NAN", &Float{Value: math.NaN()}},
+}
+
+var ordinals = []string{"first", "second", "third", "fourth", "fifth", "sixth", "seventh", "eighth", "ninth", "tenth"}
+
+// argumentName names argument i of args in an error message: "argument"
+// for the only argument and "first argument" and so on otherwise.
+func argumentName(args []Object, i int) string {
+	switch {
+	case len(args) == 1:
+		return "argument"
+	case i < len(ordinals):
+		return ordinals[i] + " argument"
+	default:
+		return fmt.Sprintf("argument %d", i+1)
+	}
+}
+
+// numberArgument returns argument i of the builtin called name, which must
+// be an integer or a float, as a float64.
+func numberArgument(name string, args []Object, i int) (float64, *Error) {
+	switch arg := args[i].(type) {
+	case *Integer, *BigInt:
+		return IntegerToFloat(arg), nil
+	case *Float:
+		return arg.Value, nil
+	case nil:
+		return 0, newError("%s to `%s` cannot be nil", argumentName(args, i), name)
+	default:
+		return 0, newError("%s to `%s` must be INTEGER or FLOAT, got %s",
+			argumentName(args, i), name, TypeName(arg))
+	}
+}
+
+// mathFunction returns a builtin that applies fn to its single numeric
+// argument and returns a float.
+func mathFunction(name string, fn func(float64) float64) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1",
+				len(args))
+		}
+		x, err := numberArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+		return &Float{Value: fn(x)}
+	},
+	}
+}
+
+// roundingFunction returns a builtin that rounds its numeric argument to
+// an integer with round. Integers are returned unchanged.
+func roundingFunction(name string, round func(float64) float64) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1",
+				len(args))
+		}
+		if IsInteger(args[0]) {
+			return args[0]
+		}
+		x, err := numberArgument(name, args, 0)
+		if err != nil {
+			return err
+		}
+		result, convErr := FloatToInteger(round(x))
+		if convErr != nil {
+			return newError("%s", convErr)
+		}
+		return result
+	},
+	}
+}
+
+// FloatToInteger converts an integral float to an Integer, or to a BigInt
+// if it does not fit in an int64.
+func FloatToInteger(f float64) (Object, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("cannot convert %s to INTEGER", FormatFloat(f))
+	}
+	if f >= math.MinInt64 && f < math.MaxInt64 {
+		return NewInteger(int64(f)), nil
+	}
+	result, _ := big.NewFloat(f).Int(nil)
+	return NewBigInt(result), nil
+}
+
+// numbersArgument returns the numbers min, max and sum work on: either
+// their arguments or the elements of a single array argument.
+func numbersArgument(name string, args []Object) ([]Object, *Error) {
+	if len(args) == 0 {
+		return nil, newError("wrong number of arguments. got=0, want at least 1")
+	}
+	if array, ok := args[0].(*Array); ok && len(args) == 1 {
+		for _, el := range array.Elements {
+			if !isNumber(el) {
+				return nil, newError("elements of the array passed to `%s` must be INTEGER or FLOAT, got %s",
+					name, TypeName(el))
+			}
+		}
+		return array.Elements, nil
+	}
+	for i := range args {
+		if _, err := numberArgument(name, args, i); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+// extremum returns the smallest number if sign is -1 and the largest if
+// it is +1. The winning number is returned as it was passed, the first of
+// several equal ones, and a NaN wins over every other number.
+func extremum(numbers []Object, sign int) Object {
+	operator := ">"
+	if sign < 0 {
+		operator = "<"
+	}
+
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		if isNaN(result) {
+			break
+		}
+		if wins, _ := Compare(operator, n, result); wins || isNaN(n) {
+			result = n
+		}
+	}
+	return result
+}
+
+func isNaN(obj Object) bool {
+	f, ok := obj.(*Float)
+	return ok && math.IsNaN(f.Value)
+}
+
+// sumNumbers adds numbers exactly while they are integers and as floats
+// once a float is involved.
+func sumNumbers(numbers []Object) Object {
+	var sum Object = NewInteger(0)
+	for _, n := range numbers {
+		if IsInteger(sum) && IsInteger(n) {
+			sum, _ = IntegerArithmetic("+", sum, n)
+			continue
+		}
+		sum = &Float{Value: toFloat(sum) + toFloat(n)}
+	}
+	return sum
+}
+
+// Pow raises base to exponent. An integer raised to a non-negative integer
+// of at most 2^20 is computed exactly; everything else is computed with
+// floats.
+func Pow(base, exponent Object) Object {
+	if IsInteger(base) && IsInteger(exponent) && CompareIntegers(exponent, NewInteger(0)) >= 0 {
+		if exp, ok := exponent.(*Integer); !ok || exp.Value > 1<<20 {
+			return &Float{Value: math.Pow(toFloat(base), toFloat(exponent))}
+		}
+		return NewBigInt(new(big.Int).Exp(toBigInt(base), toBigInt(exponent), nil))
+	}
+	return &Float{Value: math.Pow(toFloat(base), toFloat(exponent))}
+}
+
+// hostSource draws from the Random source of a host, or from the global
+// source of math/rand if it has none. It holds the lock of the host, so
+// that engines sharing the host never use the source at the same time.
+type hostSource struct {
+	host *Host
+}
+
+func (s hostSource) Uint64() uint64 {
+	s.host.mu.Lock()
+	defer s.host.mu.Unlock()
+	if s.host.Random == nil {
+		return rand.Uint64()
+	}
+	return s.host.Random.Uint64()
+}
+
+func (h *Host) random() *rand.Rand {
+	return rand.New(hostSource{host: h})
+}
+
+// seed replaces the Random source of h with a deterministic one.
+func (h *Host) seed(value int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.Random = NewRandom(value)
+}
+
+// NewRandom returns a random number generator that produces the same
+// sequence every time it is created with the same seed.
+func NewRandom(seed int64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(seed), 0))
+}
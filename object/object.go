@@ -2,11 +2,15 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/code"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -20,6 +24,7 @@ const (
 	FUNCTION_OBJ = "FUNCTION"
 
 	STRING_OBJ = "STRING"
+	BYTES_OBJ  = "BYTES"
 
 	BUILTIN_OBJ = "BUILTIN"
 
@@ -36,10 +41,58 @@ const (
 
 	BREAK_OBJ    = "BREAK"
 	CONTINUE_OBJ = "CONTINUE"
+
+	GENERATOR_OBJ = "GENERATOR"
+	ITERATOR_OBJ  = "ITERATOR"
+
+	STRUCT_OBJ       = "STRUCT"
+	BOUND_METHOD_OBJ = "BOUND_METHOD"
+
+	SET_OBJ = "SET"
+
+	TIME_OBJ     = "TIME"
+	DURATION_OBJ = "DURATION"
+
+	BIGINT_OBJ = "BIGINT"
 )
 
 type ObjectType string
 
+// builtinTypes are the types of the built-in values. Structs may not be
+// named after them, so that an instance is never mistaken for one.
+var builtinTypes = map[ObjectType]bool{
+	INTEGER_OBJ:           true,
+	BOOLEAN_OBJ:           true,
+	NULL_OBJ:              true,
+	RETURN_VALUE_OBJ:      true,
+	ERROR_OBJ:             true,
+	FUNCTION_OBJ:          true,
+	STRING_OBJ:            true,
+	BYTES_OBJ:             true,
+	BUILTIN_OBJ:           true,
+	ARRAY_OBJ:             true,
+	HASH_OBJ:              true,
+	COMPILED_FUNCTION_OBJ: true,
+	CLOSURE_OBJ:           true,
+	FLOAT_OBJ:             true,
+	REGEX_OBJ:             true,
+	BREAK_OBJ:             true,
+	CONTINUE_OBJ:          true,
+	GENERATOR_OBJ:         true,
+	ITERATOR_OBJ:          true,
+	STRUCT_OBJ:            true,
+	BOUND_METHOD_OBJ:      true,
+	SET_OBJ:               true,
+	TIME_OBJ:              true,
+	DURATION_OBJ:          true,
+	BIGINT_OBJ:            true,
+}
+
+// IsBuiltinType reports whether name is the type of a built-in value.
+func IsBuiltinType(name string) bool {
+	return builtinTypes[ObjectType(name)]
+}
+
 type Object interface {
 	Type() ObjectType
 	Inspect() string
@@ -71,17 +124,25 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Error is a runtime error. Exit is set on the error returned by exit(),
+// which unwinds the script like any other error but stands for a normal
+// end with a status rather than a failure. Fatal is set on the error a VM
+// hands back when a callback or generator body it ran failed, which must
+// stop the calling VM as well instead of becoming a value.
 type Error struct {
 	Message string
+	Exit    *ExitError
+	Fatal   bool
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 type Function struct {
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	Parameters  []*ast.Identifier
+	Body        *ast.BlockStatement
+	Env         *Environment
+	IsGenerator bool
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -112,8 +173,31 @@ func (s *String) Inspect() string  { return s.Value }
 
 type BuiltinFunction func(args ...Object) Object
 
+// Caller calls a function value with arguments and returns its result, or
+// an *Error if the call fails. Both engines implement it so that builtins
+// can call the functions they are passed. An engine that also has a
+// Host() *Host method supplies its own host to the builtins it runs.
+type Caller interface {
+	Call(fn Object, args ...Object) Object
+}
+
+// CallbackFunction is a builtin that calls back into functions through
+// caller.
+type CallbackFunction func(caller Caller, args ...Object) Object
+
 type Builtin struct {
 	Fn BuiltinFunction
+	// CallbackFn is set instead of Fn by builtins that take functions as
+	// arguments or that use the host of the engine running them.
+	CallbackFn CallbackFunction
+}
+
+// Apply calls the builtin, handing caller to builtins that need it.
+func (b *Builtin) Apply(caller Caller, args ...Object) Object {
+	if b.CallbackFn != nil {
+		return b.CallbackFn(caller, args...)
+	}
+	return b.Fn(args...)
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -121,6 +205,9 @@ func (b *Builtin) Inspect() string  { return "builtin function" }
 
 type Array struct {
 	Elements []Object
+	// claim is shared with the arrays whose Elements view the same backing
+	// array; see Push.
+	claim *appendClaim
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
@@ -170,8 +257,99 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash maps keys to values. It is backed by a hash array mapped trie, so
+// Put and Delete return a new hash that shares all untouched pairs with the
+// old one. order records the keys in insertion order so that Inspect,
+// iteration and JSON output are deterministic; it is only ever appended to,
+// and keys that were deleted or re-added later are skipped when reading it.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	root  *hamtNode
+	size  int
+	order []HashKey
+	claim *appendClaim
+}
+
+func NewHash() *Hash {
+	return &Hash{}
+}
+
+// Len returns the number of pairs.
+func (h *Hash) Len() int { return h.size }
+
+// Get returns the pair for key.
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	entry, ok := h.root.get(key, 0)
+	return entry.pair, ok
+}
+
+// Set adds or replaces the pair for key in place. It is meant for filling
+// a hash that has not been handed out yet; use Put to derive a new one.
+// A new key goes after all existing keys; replacing a value keeps the key
+// in its place.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	*h = *h.Put(key, pair)
+}
+
+// Put returns a new hash with the pair for key added or replaced.
+func (h *Hash) Put(key HashKey, pair HashPair) *Hash {
+	result := *h
+	entry := hashEntry{key: key, pair: pair, seq: len(h.order)}
+	if existing, ok := h.root.get(key, 0); ok {
+		entry.seq = existing.seq
+	} else {
+		result.order, result.claim = appendShared(h.order, h.claim, key)
+		result.size++
+	}
+	result.root, _ = h.root.put(entry, 0)
+	return &result
+}
+
+// Delete returns a new hash without the pair for key.
+func (h *Hash) Delete(key HashKey) *Hash {
+	root, removed := h.root.remove(key, 0)
+	if !removed {
+		return h
+	}
+	result := &Hash{root: root, size: h.size - 1, order: h.order, claim: h.claim}
+	if len(result.order) > 2*result.size+8 {
+		result.compact()
+	}
+	return result
+}
+
+// compact rebuilds the hash so that order no longer holds deleted keys.
+func (h *Hash) compact() {
+	old := *h
+	*h = Hash{}
+	for seq, key := range old.order {
+		if entry, ok := old.root.get(key, 0); ok && entry.seq == seq {
+			h.Set(key, entry.pair)
+		}
+	}
+}
+
+// Pairs returns the pairs by key, as the Pairs field of earlier versions
+// held them. The map is a copy, so changing it does not change the hash,
+// and it has no order; OrderedPairs returns the pairs in insertion order.
+func (h *Hash) Pairs() map[HashKey]HashPair {
+	pairs := make(map[HashKey]HashPair, h.size)
+	for seq, key := range h.order {
+		if entry, ok := h.root.get(key, 0); ok && entry.seq == seq {
+			pairs[key] = entry.pair
+		}
+	}
+	return pairs
+}
+
+// OrderedPairs returns the pairs in insertion order.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, h.size)
+	for seq, key := range h.order {
+		if entry, ok := h.root.get(key, 0); ok && entry.seq == seq {
+			pairs = append(pairs, entry.pair)
+		}
+	}
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -179,7 +357,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -194,10 +372,145 @@ type Hashable interface {
 	HashKey() HashKey
 }
 
+func (f *Float) HashKey() HashKey {
+	// Integral floats hash like the equal integer, as 2 == 2.0
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+			return NewInteger(int64(f.Value)).HashKey()
+		}
+		value, _ := new(big.Float).SetFloat64(f.Value).Int(nil)
+		return (&BigInt{Value: value}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+func (n *Null) HashKey() HashKey {
+	return HashKey{Type: n.Type(), Value: 0}
+}
+
+// HashKeyOf returns the hash key of obj. Besides Hashable values, arrays
+// whose elements are all hashable have a key derived from their elements,
+// so that they can be used as composite keys such as {[x, y]: cell}.
+func HashKeyOf(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Array:
+		h := fnv.New64a()
+		buf := make([]byte, 8)
+		for _, el := range obj.Elements {
+			key, ok := HashKeyOf(el)
+			if !ok {
+				return HashKey{}, false
+			}
+			h.Write([]byte(key.Type))
+			binary.LittleEndian.PutUint64(buf, key.Value)
+			h.Write(buf)
+		}
+		return HashKey{Type: obj.Type(), Value: h.Sum64()}, true
+	default:
+		return HashKey{}, false
+	}
+}
+
+// Set is a collection of distinct hashable values. Elements are kept in
+// insertion order so that Inspect and iteration are deterministic.
+type Set struct {
+	Elements map[HashKey]Object
+	keys     []HashKey
+}
+
+func NewSet() *Set {
+	return &Set{Elements: make(map[HashKey]Object)}
+}
+
+func (s *Set) Type() ObjectType { return SET_OBJ }
+func (s *Set) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range s.Values() {
+		elements = append(elements, el.Inspect())
+	}
+
+	out.WriteString("#{")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Add inserts obj, reporting false if obj is not hashable.
+func (s *Set) Add(obj Object) bool {
+	key, ok := HashKeyOf(obj)
+	if !ok {
+		return false
+	}
+
+	if _, exists := s.Elements[key]; !exists {
+		s.Elements[key] = obj
+		s.keys = append(s.keys, key)
+	}
+	return true
+}
+
+func (s *Set) Contains(obj Object) bool {
+	key, ok := HashKeyOf(obj)
+	if !ok {
+		return false
+	}
+
+	_, exists := s.Elements[key]
+	return exists
+}
+
+func (s *Set) Len() int { return len(s.keys) }
+
+// Values returns the elements in insertion order.
+func (s *Set) Values() []Object {
+	values := make([]Object, len(s.keys))
+	for i, key := range s.keys {
+		values[i] = s.Elements[key]
+	}
+	return values
+}
+
+func (s *Set) Union(other *Set) *Set {
+	result := NewSet()
+	for _, el := range s.Values() {
+		result.Add(el)
+	}
+	for _, el := range other.Values() {
+		result.Add(el)
+	}
+	return result
+}
+
+func (s *Set) Intersection(other *Set) *Set {
+	result := NewSet()
+	for _, el := range s.Values() {
+		if other.Contains(el) {
+			result.Add(el)
+		}
+	}
+	return result
+}
+
+func (s *Set) Difference(other *Set) *Set {
+	result := NewSet()
+	for _, el := range s.Values() {
+		if !other.Contains(el) {
+			result.Add(el)
+		}
+	}
+	return result
+}
+
 type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	IsGenerator   bool
 }
 
 func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
@@ -220,7 +533,37 @@ type Float struct {
 }
 
 func (f *Float) Type() ObjectType { return FLOAT_OBJ }
-func (f *Float) Inspect() string  { return fmt.Sprintf("%f", f.Value) }
+func (f *Float) Inspect() string  { return FormatFloat(f.Value) }
+
+// FormatFloat returns the shortest representation of f that parses back to
+// the same value. Like JSON numbers in JavaScript, it uses an exponent only
+// for very small or very large magnitudes; integral values keep a ".0" so
+// they read as floats. NaN and the infinities print as NaN, Inf and -Inf.
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		str := strconv.FormatFloat(f, 'e', -1, 64)
+		// Drop the leading zero of two-digit exponents: 1e-07 -> 1e-7
+		if n := len(str); n >= 4 && str[n-2] == '0' && (str[n-3] == '-' || str[n-3] == '+') {
+			str = str[:n-2] + str[n-1:]
+		}
+		return str
+	}
+
+	str := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(str, ".") {
+		str += ".0"
+	}
+	return str
+}
 
 type Break struct{}
 
@@ -234,11 +577,342 @@ func (c *Continue) Inspect() string  { return "continue" }
 
 type Regex struct {
 	Pattern string
+	Flags   string
 	Regexp  *regexp.Regexp
 }
 
 func (r *Regex) Type() ObjectType { return REGEX_OBJ }
-func (r *Regex) Inspect() string  { return fmt.Sprintf("/%s/", r.Pattern) }
+func (r *Regex) Inspect() string  { return fmt.Sprintf("/%s/%s", r.Pattern, r.Flags) }
+
+// StructType is a user type declared with a struct statement. Calling it
+// constructs an Instance; impl statements attach methods to it.
+type StructType struct {
+	Name    string
+	Fields  []string
+	Methods map[string]Object
+}
+
+func NewStructType(name string, fields []string) *StructType {
+	return &StructType{Name: name, Fields: fields, Methods: make(map[string]Object)}
+}
+
+func (st *StructType) Type() ObjectType { return STRUCT_OBJ }
+func (st *StructType) Inspect() string {
+	return fmt.Sprintf("struct %s { %s }", st.Name, strings.Join(st.Fields, ", "))
+}
+
+// Construct returns a new instance with args assigned to the fields in
+// declaration order, or an error if the number of args does not match.
+func (st *StructType) Construct(args []Object) Object {
+	if len(args) != len(st.Fields) {
+		return newError("wrong number of arguments to %s: want=%d, got=%d",
+			st.Name, len(st.Fields), len(args))
+	}
+
+	fields := make([]Object, len(args))
+	copy(fields, args)
+
+	return &Instance{Struct: st, Fields: fields}
+}
+
+// DefineMethod attaches a method, reporting false if the name is already
+// taken by a field.
+func (st *StructType) DefineMethod(name string, method Object) bool {
+	if st.fieldIndex(name) >= 0 {
+		return false
+	}
+	st.Methods[name] = method
+	return true
+}
+
+// Member returns the unbound method called name, so that Point.norm(p) is
+// the same as p.norm().
+func (st *StructType) Member(name string) (Object, bool) {
+	method, ok := st.Methods[name]
+	return method, ok
+}
+
+func (st *StructType) fieldIndex(name string) int {
+	for i, field := range st.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Instance is a value of a user-defined struct type. Its Type is the name
+// of the struct, which may not be the name of a built-in type.
+type Instance struct {
+	Struct *StructType
+	Fields []Object
+}
+
+func (i *Instance) Type() ObjectType { return ObjectType(i.Struct.Name) }
+func (i *Instance) Inspect() string {
+	var out bytes.Buffer
+
+	fields := []string{}
+	for idx, name := range i.Struct.Fields {
+		fields = append(fields, name+": "+i.Fields[idx].Inspect())
+	}
+
+	out.WriteString(i.Struct.Name)
+	out.WriteString("{")
+	out.WriteString(strings.Join(fields, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Member returns the field called name, or the method called name bound to
+// the instance.
+func (i *Instance) Member(name string) (Object, bool) {
+	if idx := i.Struct.fieldIndex(name); idx >= 0 {
+		return i.Fields[idx], true
+	}
+	if method, ok := i.Struct.Methods[name]; ok {
+		return &BoundMethod{Receiver: i, Method: method}, true
+	}
+	return nil, false
+}
+
+// Member is implemented by objects that support the '.' operator.
+type Member interface {
+	Member(name string) (Object, bool)
+}
+
+// BoundMethod is a method together with the instance it was looked up on.
+// Calling it passes the receiver as the first argument.
+type BoundMethod struct {
+	Receiver Object
+	Method   Object
+}
+
+func (bm *BoundMethod) Type() ObjectType { return BOUND_METHOD_OBJ }
+func (bm *BoundMethod) Inspect() string {
+	return fmt.Sprintf("method of %s", bm.Receiver.Inspect())
+}
+
+// Iterator is implemented by objects that produce their values one at a
+// time. Next reports false once the values are exhausted.
+type Iterator interface {
+	Object
+	Next() (Object, bool)
+}
+
+// Generator is the value returned by calling a function that contains a
+// yield statement. Each engine supplies resume, which runs the suspended
+// function body up to its next yield, and optionally close, which releases
+// what a suspended body holds on to.
+type Generator struct {
+	resume func() (Object, bool)
+	close  func()
+	done   bool
+}
+
+func NewGenerator(resume func() (Object, bool), close func()) *Generator {
+	return &Generator{resume: resume, close: close}
+}
+
+func (g *Generator) Type() ObjectType { return GENERATOR_OBJ }
+func (g *Generator) Inspect() string  { return fmt.Sprintf("Generator[%p]", g) }
+
+// Next resumes the generator. An error raised by the body is returned as
+// the final value.
+func (g *Generator) Next() (Object, bool) {
+	if g.done {
+		return nil, false
+	}
+	value, ok := g.resume()
+	if !ok {
+		g.done = true
+		return nil, false
+	}
+	if value.Type() == ERROR_OBJ {
+		g.done = true
+	}
+	return value, true
+}
+
+// Close ends a generator that will not be resumed again. A generator that
+// has already finished is left as it is.
+func (g *Generator) Close() {
+	if g.done {
+		return
+	}
+	g.done = true
+	if g.close != nil {
+		g.close()
+	}
+}
+
+type arrayIterator struct {
+	elements []Object
+	index    int
+}
+
+func (ai *arrayIterator) Type() ObjectType { return ITERATOR_OBJ }
+func (ai *arrayIterator) Inspect() string  { return "iterator" }
+
+func (ai *arrayIterator) Next() (Object, bool) {
+	if ai.index >= len(ai.elements) {
+		return nil, false
+	}
+	value := ai.elements[ai.index]
+	ai.index++
+	return value, true
+}
+
+// Iterate returns an iterator over the values of obj for use by for-in
+// loops: the elements of an array, the characters of a string, or the
+// values of an iterator itself.
+func Iterate(obj Object) (Iterator, bool) {
+	switch obj := obj.(type) {
+	case Iterator:
+		return obj, true
+	case *Array:
+		return &arrayIterator{elements: obj.Elements}, true
+	case *Set:
+		return &arrayIterator{elements: obj.Values()}, true
+	case *Hash:
+		keys := []Object{}
+		for _, pair := range obj.OrderedPairs() {
+			keys = append(keys, pair.Key)
+		}
+		return &arrayIterator{elements: keys}, true
+	case *Bytes:
+		values := make([]Object, len(obj.Value))
+		for i, b := range obj.Value {
+			values[i] = NewInteger(int64(b))
+		}
+		return &arrayIterator{elements: values}, true
+	case *String:
+		chars := []Object{}
+		for _, r := range obj.Value {
+			chars = append(chars, &String{Value: string(r)})
+		}
+		return &arrayIterator{elements: chars}, true
+	default:
+		return nil, false
+	}
+}
+
+// Contains implements the `in` operator: membership in a set, a key of a
+// hash, an element of an array, a substring of a string or a byte or byte
+// sequence in bytes. ok is false if the operands do not support membership
+// tests.
+func Contains(container, item Object) (found bool, ok bool) {
+	switch container := container.(type) {
+	case *Set:
+		return container.Contains(item), true
+	case *Hash:
+		key, isHashable := HashKeyOf(item)
+		if !isHashable {
+			return false, true
+		}
+		_, exists := container.Get(key)
+		return exists, true
+	case *Array:
+		for _, el := range container.Elements {
+			if Equals(el, item) {
+				return true, true
+			}
+		}
+		return false, true
+	case *String:
+		str, isString := item.(*String)
+		if !isString {
+			return false, false
+		}
+		return strings.Contains(container.Value, str.Value), true
+	case *Bytes:
+		switch item := item.(type) {
+		case *Bytes:
+			return bytes.Contains(container.Value, item.Value), true
+		case *Integer:
+			return item.Value >= 0 && item.Value <= 255 &&
+				bytes.IndexByte(container.Value, byte(item.Value)) >= 0, true
+		default:
+			return false, false
+		}
+	default:
+		return false, false
+	}
+}
+
+// IsSequence reports whether obj is an array, string or bytes value, the
+// values that are indexed by position.
+func IsSequence(obj Object) bool {
+	switch obj.(type) {
+	case *Array, *String, *Bytes:
+		return true
+	default:
+		return false
+	}
+}
+
+// Slice implements left[start:end] for arrays, strings and bytes. start and
+// end are integers or NULL for an omitted bound. A negative bound counts
+// from the end of left, and bounds are then clamped to its length, so a
+// slice is never out of range but may be empty. Strings are sliced by code
+// point.
+func Slice(left, start, end Object) (Object, error) {
+	var length int
+	switch left := left.(type) {
+	case *Array:
+		length = len(left.Elements)
+	case *String:
+		length = StringLength(left.Value)
+	case *Bytes:
+		length = len(left.Value)
+	default:
+		return nil, fmt.Errorf("slice operator not supported: %s", TypeName(left))
+	}
+
+	lo, err := sliceBound(start, 0, length)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := sliceBound(end, length, length)
+	if err != nil {
+		return nil, err
+	}
+	if hi < lo {
+		hi = lo
+	}
+
+	switch left := left.(type) {
+	case *Array:
+		return &Array{Elements: left.Elements[lo:hi], claim: left.claim}, nil
+	case *String:
+		start, end := runeOffsets(left.Value, lo, hi)
+		return &String{Value: left.Value[start:end]}, nil
+	default:
+		return &Bytes{Value: left.(*Bytes).Value[lo:hi:hi]}, nil
+	}
+}
+
+func sliceBound(bound Object, omitted, length int) (int, error) {
+	switch bound := bound.(type) {
+	case *Null:
+		return omitted, nil
+	case *Integer:
+		value := bound.Value
+		if value < 0 {
+			value += int64(length)
+		}
+		return int(max(0, min(value, int64(length)))), nil
+	case *BigInt:
+		// Too large to be anything but beyond either end
+		if bound.Value.Sign() < 0 {
+			return 0, nil
+		}
+		return length, nil
+	default:
+		return 0, fmt.Errorf("slice bounds must be INTEGER, got %s", TypeName(bound))
+	}
+}
 
 // Shared singleton instances to reduce memory allocation
 var (
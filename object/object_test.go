@@ -1,9 +1,18 @@
 package object
 
 import (
+	"cmp"
+	"errors"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestStringHashkey(t *testing.T) {
@@ -25,6 +34,700 @@ func TestStringHashkey(t *testing.T) {
 	}
 }
 
+func TestStructInstances(t *testing.T) {
+	point := NewStructType("Point", []string{"x", "y"})
+	point.DefineMethod("norm", &Builtin{})
+
+	p := point.Construct([]Object{&Integer{Value: 1}, &Integer{Value: 2}})
+
+	if p.Type() != "Point" {
+		t.Errorf("instance has wrong type. want=%q, got=%q", "Point", p.Type())
+	}
+	if p.Inspect() != "Point{x: 1, y: 2}" {
+		t.Errorf("instance has wrong Inspect. got=%q", p.Inspect())
+	}
+	if point.Inspect() != "struct Point { x, y }" {
+		t.Errorf("struct has wrong Inspect. got=%q", point.Inspect())
+	}
+
+	instance := p.(*Instance)
+	if y, ok := instance.Member("y"); !ok || y.(*Integer).Value != 2 {
+		t.Errorf("field y not found or wrong. got=%v", y)
+	}
+	if method, ok := instance.Member("norm"); !ok || method.(*BoundMethod).Receiver != p {
+		t.Errorf("method norm not bound to instance. got=%v", method)
+	}
+	if _, ok := instance.Member("z"); ok {
+		t.Errorf("unknown member z was found")
+	}
+	if point.DefineMethod("x", &Builtin{}) {
+		t.Errorf("method x defined over field x")
+	}
+
+	err, ok := point.Construct([]Object{&Integer{Value: 1}}).(*Error)
+	if !ok || err.Message != "wrong number of arguments to Point: want=2, got=1" {
+		t.Errorf("expected arity error, got=%v", err)
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	newSet := func(values ...int64) *Set {
+		set := NewSet()
+		for _, v := range values {
+			set.Add(&Integer{Value: v})
+		}
+		return set
+	}
+
+	a := newSet(1, 2, 3, 2)
+	b := newSet(3, 4)
+
+	tests := []struct {
+		set      *Set
+		expected string
+	}{
+		{a, "#{1, 2, 3}"},
+		{a.Union(b), "#{1, 2, 3, 4}"},
+		{a.Intersection(b), "#{3}"},
+		{a.Difference(b), "#{1, 2}"},
+		{b.Difference(a), "#{4}"},
+		{NewSet(), "#{}"},
+	}
+
+	for _, tt := range tests {
+		if tt.set.Inspect() != tt.expected {
+			t.Errorf("wrong set. want=%q, got=%q", tt.expected, tt.set.Inspect())
+		}
+	}
+
+	if a.Len() != 3 {
+		t.Errorf("wrong length. want=3, got=%d", a.Len())
+	}
+	if !a.Contains(&Integer{Value: 2}) || a.Contains(&String{Value: "2"}) {
+		t.Errorf("wrong membership for 2 and \"2\"")
+	}
+	if a.Add(NewHash()) {
+		t.Errorf("hash added to set")
+	}
+
+	result := GetBuiltinByName("json_stringify").Fn(a)
+	if str, ok := result.(*String); !ok || str.Value != "[1,2,3]" {
+		t.Errorf("wrong JSON for set. got=%s", result.Inspect())
+	}
+}
+
+func TestIntegerArithmeticPromotion(t *testing.T) {
+	bigInt := func(s string) Object {
+		value, _ := new(big.Int).SetString(s, 10)
+		return NewBigInt(value)
+	}
+
+	tests := []struct {
+		operator    string
+		left, right Object
+		expected    string
+		bigResult   bool
+	}{
+		{"+", NewInteger(1), NewInteger(2), "3", false},
+		{"+", NewInteger(math.MaxInt64), NewInteger(1), "9223372036854775808", true},
+		{"-", NewInteger(math.MinInt64), NewInteger(1), "-9223372036854775809", true},
+		{"*", NewInteger(math.MaxInt64), NewInteger(2), "18446744073709551614", true},
+		{"*", NewInteger(-1), NewInteger(math.MinInt64), "9223372036854775808", true},
+		{"/", NewInteger(math.MinInt64), NewInteger(-1), "9223372036854775808", true},
+		{"/", NewInteger(-7), NewInteger(2), "-3", false},
+		{"-", bigInt("9223372036854775808"), NewInteger(1), "9223372036854775807", false},
+		{"*", bigInt("18446744073709551616"), bigInt("18446744073709551616"), "340282366920938463463374607431768211456", true},
+		{"/", bigInt("18446744073709551616"), NewInteger(4294967296), "4294967296", false},
+	}
+
+	for _, tt := range tests {
+		result, err := IntegerArithmetic(tt.operator, tt.left, tt.right)
+		if err != nil {
+			t.Fatalf("%s %s %s: unexpected error: %s", tt.left.Inspect(), tt.operator, tt.right.Inspect(), err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s %s %s: want=%s, got=%s", tt.left.Inspect(), tt.operator, tt.right.Inspect(), tt.expected, result.Inspect())
+		}
+		if _, isBig := result.(*BigInt); isBig != tt.bigResult {
+			t.Errorf("%s %s %s: result is %T", tt.left.Inspect(), tt.operator, tt.right.Inspect(), result)
+		}
+	}
+
+	// Demoted results come from the small integer cache
+	result, _ := IntegerArithmetic("-", bigInt("9223372036854775808"), bigInt("9223372036854775800"))
+	if result != NewInteger(8) {
+		t.Errorf("demoted result is not the cached integer. got=%p, want=%p", result, NewInteger(8))
+	}
+
+	if _, err := IntegerArithmetic("/", NewInteger(1), NewInteger(0)); err == nil || err.Error() != "division by zero" {
+		t.Errorf("expected division by zero error, got=%v", err)
+	}
+
+	if CompareIntegers(bigInt("9223372036854775808"), NewInteger(math.MaxInt64)) != 1 {
+		t.Errorf("big integer does not compare greater than MaxInt64")
+	}
+	if NegateInteger(NewInteger(math.MinInt64)).Inspect() != "9223372036854775808" {
+		t.Errorf("negating MinInt64 did not promote")
+	}
+	if bigInt("12345678901234567890").(Hashable).HashKey() != bigInt("12345678901234567890").(Hashable).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+
+	stringify := GetBuiltinByName("json_stringify").Fn(&Array{Elements: []Object{bigInt("12345678901234567890")}})
+	if stringify.Inspect() != "[12345678901234567890]" {
+		t.Errorf("wrong JSON for big integer. got=%s", stringify.Inspect())
+	}
+	parsed := GetBuiltinByName("json_parse").Fn(&String{Value: "[12345678901234567890, 5, 2.5, 3.0]"})
+	if parsed.Inspect() != "[12345678901234567890, 5, 2.5, 3]" {
+		t.Errorf("wrong json_parse result. got=%s", parsed.Inspect())
+	}
+}
+
+func TestCompare(t *testing.T) {
+	nan := &Float{Value: math.NaN()}
+
+	tests := []struct {
+		operator    string
+		left, right Object
+		expected    bool
+		ok          bool
+	}{
+		{"<", NewInteger(1), &Float{Value: 1.5}, true, true},
+		{"==", &Float{Value: 2}, NewInteger(2), true, true},
+		{"==", &String{Value: "x"}, &String{Value: "x"}, true, true},
+		{"<", &String{Value: "B"}, &String{Value: "a"}, true, true},
+		{">=", &String{Value: "a"}, &String{Value: "ab"}, false, true},
+		{"==", nan, nan, false, true},
+		{"!=", nan, nan, true, true},
+		{"<", nan, NewInteger(1), false, true},
+		{"==", NewInteger(1), &String{Value: "1"}, false, true},
+		{"==", TRUE, TRUE, true, true},
+		{"!=", NULL, NULL, false, true},
+		{"<", TRUE, FALSE, false, false},
+		{"<", NewInteger(1), &String{Value: "1"}, false, false},
+		{"+", NewInteger(1), NewInteger(2), false, false},
+	}
+
+	for _, tt := range tests {
+		result, ok := Compare(tt.operator, tt.left, tt.right)
+		if ok != tt.ok || result != tt.expected {
+			t.Errorf("%s %s %s: want=(%t, %t), got=(%t, %t)", tt.left.Inspect(), tt.operator,
+				tt.right.Inspect(), tt.expected, tt.ok, result, ok)
+		}
+	}
+}
+
+func TestHashKeyOf(t *testing.T) {
+	pair := func(a, b Object) Object { return &Array{Elements: []Object{a, b}} }
+
+	same := [][2]Object{
+		{pair(NewInteger(1), NewInteger(2)), pair(NewInteger(1), NewInteger(2))},
+		{pair(NewInteger(1), &Float{Value: 2}), pair(NewInteger(1), NewInteger(2))},
+		{&Float{Value: 3}, NewInteger(3)},
+		{&Float{Value: 1e20}, NewBigInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(20), nil))},
+		{&Float{Value: 0.5}, &Float{Value: 0.5}},
+		{NULL, NULL},
+	}
+	for _, tt := range same {
+		a, aok := HashKeyOf(tt[0])
+		b, bok := HashKeyOf(tt[1])
+		if !aok || !bok || a != b {
+			t.Errorf("%s and %s hash differently", tt[0].Inspect(), tt[1].Inspect())
+		}
+	}
+
+	a, _ := HashKeyOf(pair(NewInteger(1), NewInteger(2)))
+	b, _ := HashKeyOf(pair(NewInteger(2), NewInteger(1)))
+	if a == b {
+		t.Errorf("[1, 2] and [2, 1] hash the same")
+	}
+
+	if _, ok := HashKeyOf(pair(NewInteger(1), NewHash())); ok {
+		t.Errorf("array containing a hash is hashable")
+	}
+}
+
+func TestEquals(t *testing.T) {
+	hash := func(key string, value Object) *Hash {
+		h := NewHash()
+		k := &String{Value: key}
+		h.Set(k.HashKey(), HashPair{Key: k, Value: value})
+		return h
+	}
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+
+	tests := []struct {
+		left, right Object
+		expected    bool
+	}{
+		{array(NewInteger(1), array(NewInteger(2))), array(NewInteger(1), array(NewInteger(2))), true},
+		{array(NewInteger(1)), array(NewInteger(1), NewInteger(2)), false},
+		{array(), NewHash(), false},
+		{hash("a", array(NewInteger(1))), hash("a", array(NewInteger(1))), true},
+		{hash("a", NewInteger(1)), hash("b", NewInteger(1)), false},
+		{hash("a", NewInteger(1)), hash("a", NewInteger(2)), false},
+		{NewSet(), NewSet(), true},
+	}
+
+	for _, tt := range tests {
+		if result := Equals(tt.left, tt.right); result != tt.expected {
+			t.Errorf("%s == %s: want=%t, got=%t", tt.left.Inspect(), tt.right.Inspect(), tt.expected, result)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	huge, _ := IntegerArithmetic("*", NewInteger(1<<62), NewInteger(4))
+	nan := &Float{Value: math.NaN()}
+
+	// Each value sorts strictly before the next one
+	ordered := []Object{
+		NULL,
+		FALSE,
+		TRUE,
+		nan,
+		NewInteger(-1),
+		&Float{Value: 0.5},
+		NewInteger(1),
+		huge,
+		&String{Value: ""},
+		&String{Value: "a"},
+		&Bytes{Value: []byte("a")},
+		array(),
+		array(NewInteger(1)),
+		array(NewInteger(1), NULL),
+		array(NewInteger(2)),
+		NewHash(),
+	}
+
+	for i, left := range ordered {
+		for j, right := range ordered {
+			if result := Order(left, right); result != cmp.Compare(i, j) {
+				t.Errorf("Order(%s, %s): want=%d, got=%d", left.Inspect(), right.Inspect(), cmp.Compare(i, j), result)
+			}
+		}
+	}
+
+	if result := Order(NewInteger(1), &Float{Value: 1}); result != 0 {
+		t.Errorf("Order(1, 1.0): want=0, got=%d", result)
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	for i, key := range []string{"zebra", "apple", "mango", "apple"} {
+		keyObj := &String{Value: key}
+		hash.Set(keyObj.HashKey(), HashPair{Key: keyObj, Value: NewInteger(int64(i))})
+	}
+
+	if hash.Inspect() != "{zebra: 0, apple: 3, mango: 2}" {
+		t.Errorf("wrong Inspect. got=%q", hash.Inspect())
+	}
+
+	stringify := GetBuiltinByName("json_stringify")
+	if result := stringify.Fn(hash).Inspect(); result != `{"zebra":0,"apple":3,"mango":2}` {
+		t.Errorf("wrong JSON. got=%s", result)
+	}
+
+	parsed := GetBuiltinByName("json_parse").Fn(&String{Value: `{"b": 1, "a": {"y": 2, "x": 3}, "c": [1]}`})
+	if parsed.Inspect() != "{b: 1, a: {y: 2, x: 3}, c: [1]}" {
+		t.Errorf("json_parse did not keep key order. got=%s", parsed.Inspect())
+	}
+	if result := stringify.Fn(parsed).Inspect(); result != `{"b":1,"a":{"y":2,"x":3},"c":[1]}` {
+		t.Errorf("JSON round trip changed key order. got=%s", result)
+	}
+
+	converted := convertGoValueToMonkeyObject(map[string]interface{}{"b": 1.0, "c": 2.0, "a": 3.0})
+	if converted.Inspect() != "{a: 3, b: 1, c: 2}" {
+		t.Errorf("converted map not in sorted order. got=%s", converted.Inspect())
+	}
+
+	// A deleted key that is added again goes last
+	apple := &String{Value: "apple"}
+	readded := hash.Delete(apple.HashKey()).Put(apple.HashKey(), HashPair{Key: apple, Value: NewInteger(4)})
+	if readded.Inspect() != "{zebra: 0, mango: 2, apple: 4}" {
+		t.Errorf("wrong Inspect after re-adding a key. got=%q", readded.Inspect())
+	}
+	if hash.Inspect() != "{zebra: 0, apple: 3, mango: 2}" {
+		t.Errorf("Delete and Put modified the original hash. got=%q", hash.Inspect())
+	}
+
+	pairs := hash.Pairs()
+	if len(pairs) != 3 || pairs[apple.HashKey()].Value.Inspect() != "3" {
+		t.Errorf("wrong Pairs. got=%v", pairs)
+	}
+	delete(pairs, apple.HashKey())
+	if _, ok := hash.Get(apple.HashKey()); !ok {
+		t.Errorf("changing the map returned by Pairs modified the hash")
+	}
+}
+
+func TestArrayStructuralSharing(t *testing.T) {
+	push := GetBuiltinByName("push").Fn
+	rest := GetBuiltinByName("rest").Fn
+	pop := GetBuiltinByName("pop").Fn
+
+	base := push(push(&Array{}, NewInteger(1)), NewInteger(2))
+	a := push(base, NewInteger(3))
+	b := push(base, NewInteger(4))
+	c := push(pop(a), NewInteger(5))
+	d := push(rest(a), NewInteger(6))
+
+	tests := []struct {
+		array    Object
+		expected string
+	}{
+		{base, "[1, 2]"},
+		{a, "[1, 2, 3]"},
+		{b, "[1, 2, 4]"},
+		{c, "[1, 2, 5]"},
+		{d, "[2, 3, 6]"},
+		{push(a, NewInteger(7)), "[1, 2, 3, 7]"},
+		{rest(rest(rest(a))), "[]"},
+	}
+
+	for _, tt := range tests {
+		if tt.array.Inspect() != tt.expected {
+			t.Errorf("wrong array. want=%s, got=%s", tt.expected, tt.array.Inspect())
+		}
+	}
+}
+
+func TestHashPersistence(t *testing.T) {
+	// Integer 1 and true share HashKey.Value, so they exercise collisions
+	keys := []Object{TRUE, FALSE, NULL}
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, NewInteger(int64(i)), &String{Value: strconv.Itoa(i)})
+	}
+
+	hash := NewHash()
+	versions := []*Hash{hash}
+	for i, key := range keys {
+		hashKey, _ := HashKeyOf(key)
+		hash = hash.Put(hashKey, HashPair{Key: key, Value: NewInteger(int64(i))})
+		versions = append(versions, hash)
+	}
+
+	for i, version := range versions {
+		if version.Len() != i {
+			t.Fatalf("version %d has wrong length: %d", i, version.Len())
+		}
+	}
+	for i, key := range keys {
+		hashKey, _ := HashKeyOf(key)
+		pair, ok := hash.Get(hashKey)
+		if !ok || pair.Value.(*Integer).Value != int64(i) {
+			t.Fatalf("wrong pair for %s: %+v", key.Inspect(), pair)
+		}
+		if _, ok := versions[i].Get(hashKey); ok {
+			t.Fatalf("%s present before it was added", key.Inspect())
+		}
+	}
+
+	for _, key := range keys[:len(keys)/2] {
+		hashKey, _ := HashKeyOf(key)
+		hash = hash.Delete(hashKey)
+	}
+	if hash.Len() != len(keys)-len(keys)/2 {
+		t.Fatalf("wrong length after delete: %d", hash.Len())
+	}
+	pairs := hash.OrderedPairs()
+	for i, pair := range pairs {
+		if pair.Key != keys[len(keys)/2+i] {
+			t.Fatalf("wrong order after delete at %d: %s", i, pair.Key.Inspect())
+		}
+	}
+	if last := versions[len(versions)-1]; last.Len() != len(keys) {
+		t.Fatalf("delete modified an earlier version")
+	}
+}
+
+func BenchmarkArrayPush(b *testing.B) {
+	push := GetBuiltinByName("push").Fn
+	for i := 0; i < b.N; i++ {
+		var array Object = &Array{}
+		for j := 0; j < 1000; j++ {
+			array = push(array, NewInteger(int64(j)))
+		}
+	}
+}
+
+func BenchmarkArrayRest(b *testing.B) {
+	rest := GetBuiltinByName("rest").Fn
+	elements := make([]Object, 1000)
+	for i := range elements {
+		elements[i] = NewInteger(int64(i))
+	}
+	for i := 0; i < b.N; i++ {
+		var array Object = &Array{Elements: elements}
+		for len(array.(*Array).Elements) > 0 {
+			array = rest(array)
+		}
+	}
+}
+
+func BenchmarkHashPut(b *testing.B) {
+	keys := make([]HashKey, 1000)
+	for i := range keys {
+		keys[i] = NewInteger(int64(i)).HashKey()
+	}
+	for i := 0; i < b.N; i++ {
+		hash := NewHash()
+		for j, key := range keys {
+			hash = hash.Put(key, HashPair{Key: NewInteger(int64(j)), Value: TRUE})
+		}
+	}
+}
+
+func BenchmarkHashGet(b *testing.B) {
+	hash := NewHash()
+	for i := 0; i < 1000; i++ {
+		key := NewInteger(int64(i))
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: TRUE})
+	}
+	key := NewInteger(500).HashKey()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hash.Get(key)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	data := &Bytes{Value: []byte("a\"\\\n\x00\xff~")}
+	if data.Inspect() != `b"a\"\\\n\x00\xff~"` {
+		t.Errorf("wrong Inspect. got=%s", data.Inspect())
+	}
+	if data.HashKey() == (&String{Value: string(data.Value)}).HashKey() {
+		t.Errorf("bytes and string hash the same")
+	}
+
+	for _, encoding := range []string{"utf-8", "UTF8", "latin1", "ISO-8859-1", "utf-16le", "utf_16be"} {
+		encoded, err := Encode("héllo wörld", encoding)
+		if err != nil {
+			t.Fatalf("Encode(%s) failed: %s", encoding, err)
+		}
+		decoded, err := Decode(encoded, encoding)
+		if err != nil || decoded != "héllo wörld" {
+			t.Errorf("%s round trip failed. got=%q, %v", encoding, decoded, err)
+		}
+	}
+
+	if _, err := Encode("日本", "latin1"); err == nil {
+		t.Errorf("expected error encoding non-latin1 text")
+	}
+	if _, err := Decode([]byte{0x80}, "ascii"); err == nil {
+		t.Errorf("expected error decoding non-ascii byte")
+	}
+	if _, err := Decode([]byte{0}, "utf-16le"); err == nil {
+		t.Errorf("expected error decoding odd-length utf-16")
+	}
+}
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"abc", []string{"a", "b", "c"}},
+		{"e\u0301x", []string{"e\u0301", "x"}},
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		{"\U0001F44D\U0001F3FD", []string{"\U0001F44D\U0001F3FD"}},
+		{"\U0001F468\u200d\U0001F469\u200d\U0001F467", []string{"\U0001F468\u200d\U0001F469\u200d\U0001F467"}},
+		{"\U0001F1EF\U0001F1F5\U0001F1E9", []string{"\U0001F1EF\U0001F1F5", "\U0001F1E9"}},
+		{"\u1100\u1161\u11a8가", []string{"\u1100\u1161\u11a8", "가"}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		got := Graphemes(tt.input)
+		if len(got) != len(tt.expected) {
+			t.Errorf("Graphemes(%q) = %q, want=%q", tt.input, got, tt.expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.expected[i] {
+				t.Errorf("Graphemes(%q) = %q, want=%q", tt.input, got, tt.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestTimeArithmetic(t *testing.T) {
+	start := &Time{Value: time.Date(2024, time.March, 30, 12, 0, 0, 0, time.UTC)}
+	day := &Duration{Value: 24 * time.Hour}
+
+	tests := []struct {
+		operator    string
+		left, right Object
+		expected    string
+	}{
+		{"+", start, day, "2024-03-31T12:00:00Z"},
+		{"+", day, start, "2024-03-31T12:00:00Z"},
+		{"-", start, day, "2024-03-29T12:00:00Z"},
+		{"-", start, &Time{Value: start.Value.Add(-90 * time.Minute)}, "1h30m0s"},
+		{"*", day, &Float{Value: 0.5}, "12h0m0s"},
+		{"/", day, NewInteger(48), "30m0s"},
+		{"/", day, &Duration{Value: time.Hour}, "24.0"},
+	}
+
+	for _, tt := range tests {
+		result, err := TimeArithmetic(tt.operator, tt.left, tt.right)
+		if err != nil {
+			t.Fatalf("%s %s %s: %s", tt.left.Inspect(), tt.operator, tt.right.Inspect(), err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s %s %s: want=%s, got=%s", tt.left.Inspect(), tt.operator,
+				tt.right.Inspect(), tt.expected, result.Inspect())
+		}
+	}
+
+	for _, operands := range [][3]Object{{start, &String{Value: "+"}, start}, {day, &String{Value: "-"}, start}} {
+		if _, err := TimeArithmetic(operands[1].Inspect(), operands[0], operands[2]); err == nil {
+			t.Errorf("expected error for %s %s %s", operands[0].Type(), operands[1].Inspect(), operands[2].Type())
+		}
+	}
+}
+
+func TestHostClock(t *testing.T) {
+	fixed := time.Unix(1700000000, 0).UTC()
+	previous := SetHost(&Host{Now: func() time.Time { return fixed }})
+	defer SetHost(previous)
+
+	result := GetBuiltinByName("now").Apply(nil)
+	if now, ok := result.(*Time); !ok || !now.Value.Equal(fixed) {
+		t.Errorf("now() did not use the host clock. got=%s", result.Inspect())
+	}
+}
+
+func TestHostRandom(t *testing.T) {
+	a, b := &Host{}, &Host{}
+	a.seed(7)
+	want := a.random().Float64()
+	if b.random().Float64(); b.Random != nil {
+		t.Errorf("unseeded host got a random source of its own")
+	}
+	b.seed(7)
+	if got := b.random().Float64(); got != want {
+		t.Errorf("hosts seeded alike differ. want=%v, got=%v", want, got)
+	}
+
+	// Engines sharing a host may use it at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				a.random().IntN(10)
+				a.seed(int64(j))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFileLinesClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines")
+	if err := os.WriteFile(path, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := fileLines(file)
+	if line, ok := lines.Next(); !ok || line.Inspect() != "a" {
+		t.Fatalf("wrong first line. got=%v", line)
+	}
+	lines.Close()
+	if _, err := file.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("file not closed with its generator. got=%v", err)
+	}
+	if _, ok := lines.Next(); ok {
+		t.Errorf("closed generator produced a line")
+	}
+}
+
+func TestHostFileRoots(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h := &Host{FileRoots: []string{root}}
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{root, true},
+		{filepath.Join(root, "a", "b"), true},
+		{filepath.Join(root, "a", "..", "b"), true},
+		{filepath.Join(root, ".."), false},
+		{root + "2", false},
+		{filepath.Join(base, "other"), false},
+	}
+
+	for _, tt := range tests {
+		_, err := h.resolvePath(tt.path)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("resolvePath(%s): want allowed=%t, got err=%v", tt.path, tt.allowed, err)
+		}
+	}
+
+	if _, err := (&Host{}).resolvePath(root); err == nil {
+		t.Errorf("expected a host without roots to deny access")
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{0.1, "0.1"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{0, "0.0"},
+		{math.Copysign(0, -1), "-0.0"},
+		{1e-9, "1e-9"},
+		{1.5e-7, "1.5e-7"},
+		{0.000001, "0.000001"},
+		{123456789012345678, "123456789012345680.0"},
+		{1e21, "1e+21"},
+		{1.7976931348623157e308, "1.7976931348623157e+308"},
+		{0.30000000000000004, "0.30000000000000004"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "Inf"},
+		{math.Inf(-1), "-Inf"},
+	}
+
+	for _, tt := range tests {
+		str := FormatFloat(tt.value)
+		if str != tt.expected {
+			t.Errorf("FormatFloat(%v): want=%s, got=%s", tt.value, tt.expected, str)
+		}
+		if math.IsNaN(tt.value) || math.IsInf(tt.value, 0) {
+			continue
+		}
+		if parsed, err := strconv.ParseFloat(str, 64); err != nil || parsed != tt.value {
+			t.Errorf("FormatFloat(%v) does not round-trip: %s", tt.value, str)
+		}
+	}
+
+	stringify := GetBuiltinByName("json_stringify").Fn
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		result := stringify(&Array{Elements: []Object{&Float{Value: value}}})
+		expected := "JSON stringify error: " + FormatFloat(value) + " is not representable in JSON"
+		if err, ok := result.(*Error); !ok || err.Message != expected {
+			t.Errorf("wrong result for %v. got=%s", value, result.Inspect())
+		}
+	}
+}
+
 func TestSplitBuiltin(t *testing.T) {
 	tests := []struct {
 		args     []Object
@@ -206,7 +909,7 @@ func TestJoinBuiltin(t *testing.T) {
 				&Array{Elements: []Object{&Integer{Value: 42}, &Float{Value: 3.14}, &String{Value: "test"}}},
 				&String{Value: "|"},
 			},
-			expected: "42|3.140000|test",
+			expected: "42|3.14|test",
 		},
 		// Edge case: empty array
 		{
@@ -254,9 +957,8 @@ func TestJoinBuiltin(t *testing.T) {
 				&Array{Elements: []Object{
 					&Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}},
 					&String{Value: "middle"},
-					&Hash{Pairs: map[HashKey]HashPair{
-						(&String{Value: "key"}).HashKey(): {Key: &String{Value: "key"}, Value: &String{Value: "value"}},
-					}},
+					NewHash().Put((&String{Value: "key"}).HashKey(),
+						HashPair{Key: &String{Value: "key"}, Value: &String{Value: "value"}}),
 				}},
 				&String{Value: " | "},
 			},
@@ -815,7 +1517,7 @@ func TestMinBuiltin(t *testing.T) {
 		},
 		{
 			args:     []Object{&Integer{Value: -2}, &Float{Value: -1.5}},
-			expected: -2.0,
+			expected: -2,
 		},
 		{
 			args:     []Object{&Float{Value: 5.0}, &Integer{Value: 5}},
@@ -839,19 +1541,31 @@ func TestMinBuiltin(t *testing.T) {
 			args:     []Object{&Float{Value: 0.0}, &Float{Value: -0.0}},
 			expected: 0.0,
 		},
-		// Error case: wrong number of arguments - too few
+		// Any number of arguments, or a single array
 		{
 			args:     []Object{&Integer{Value: 5}},
-			expected: "wrong number of arguments. got=1, want=2",
+			expected: 5,
+		},
+		{
+			args:     []Object{&Integer{Value: 1}, &Integer{Value: 3}, &Integer{Value: 2}},
+			expected: 1,
+		},
+		{
+			args:     []Object{&Array{Elements: []Object{&Integer{Value: 1}, &Float{Value: 3}, &Integer{Value: 2}}}},
+			expected: 1,
 		},
+		// Error case: no numbers
 		{
 			args:     []Object{},
-			expected: "wrong number of arguments. got=0, want=2",
+			expected: "wrong number of arguments. got=0, want at least 1",
 		},
-		// Error case: wrong number of arguments - too many
 		{
-			args:     []Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}},
-			expected: "wrong number of arguments. got=3, want=2",
+			args:     []Object{&Array{}},
+			expected: "`min` of empty array",
+		},
+		{
+			args:     []Object{&Array{Elements: []Object{&String{Value: "a"}}}},
+			expected: "elements of the array passed to `min` must be INTEGER or FLOAT, got STRING",
 		},
 		// Error case: non-numeric arguments
 		{
@@ -968,11 +1682,11 @@ func TestMaxBuiltin(t *testing.T) {
 		// Mixed int/float cases
 		{
 			args:     []Object{&Integer{Value: 3}, &Float{Value: 2.5}},
-			expected: 3.0,
+			expected: 3,
 		},
 		{
 			args:     []Object{&Float{Value: 3.14}, &Integer{Value: 4}},
-			expected: 4.0,
+			expected: 4,
 		},
 		{
 			args:     []Object{&Integer{Value: -2}, &Float{Value: -1.5}},
@@ -1000,19 +1714,31 @@ func TestMaxBuiltin(t *testing.T) {
 			args:     []Object{&Float{Value: 0.0}, &Float{Value: -0.0}},
 			expected: 0.0,
 		},
-		// Error case: wrong number of arguments - too few
+		// Any number of arguments, or a single array
 		{
 			args:     []Object{&Integer{Value: 5}},
-			expected: "wrong number of arguments. got=1, want=2",
+			expected: 5,
 		},
+		{
+			args:     []Object{&Integer{Value: 1}, &Integer{Value: 3}, &Integer{Value: 2}},
+			expected: 3,
+		},
+		{
+			args:     []Object{&Array{Elements: []Object{&Integer{Value: 1}, &Float{Value: 3}, &Integer{Value: 2}}}},
+			expected: 3.0,
+		},
+		// Error case: no numbers
 		{
 			args:     []Object{},
-			expected: "wrong number of arguments. got=0, want=2",
+			expected: "wrong number of arguments. got=0, want at least 1",
 		},
-		// Error case: wrong number of arguments - too many
 		{
-			args:     []Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}},
-			expected: "wrong number of arguments. got=3, want=2",
+			args:     []Object{&Array{}},
+			expected: "`max` of empty array",
+		},
+		{
+			args:     []Object{&Array{Elements: []Object{&String{Value: "a"}}}},
+			expected: "elements of the array passed to `max` must be INTEGER or FLOAT, got STRING",
 		},
 		// Error case: non-numeric arguments
 		{
@@ -1289,11 +2015,11 @@ func TestRegexBuiltin(t *testing.T) {
 		// Error case: wrong number of arguments
 		{
 			args:     []Object{},
-			expected: "wrong number of arguments. got=0, want=1",
+			expected: "wrong number of arguments. got=0, want=1 or 2",
 		},
 		{
-			args:     []Object{&String{Value: "test"}, &String{Value: "extra"}},
-			expected: "wrong number of arguments. got=2, want=1",
+			args:     []Object{&String{Value: "test"}, &String{Value: "i"}, &String{Value: "extra"}},
+			expected: "wrong number of arguments. got=3, want=1 or 2",
 		},
 		// Error case: non-string argument
 		{
@@ -1472,6 +2198,23 @@ func TestMatchBuiltin(t *testing.T) {
 	}
 }
 
+func TestNewRegex(t *testing.T) {
+	re, err := NewRegex("^a.b$", "smi")
+	if err != nil {
+		t.Fatalf("NewRegex failed: %s", err)
+	}
+	if re.Inspect() != "/^a.b$/ims" {
+		t.Errorf("wrong Inspect. got=%s", re.Inspect())
+	}
+	if !re.Regexp.MatchString("x\nA\nb") {
+		t.Errorf("flags not applied to %s", re.Regexp)
+	}
+
+	if _, err := NewRegex("a", "g"); err == nil || err.Error() != "unknown regex flag: g" {
+		t.Errorf("expected unknown flag error, got %v", err)
+	}
+}
+
 func TestReplaceBuiltin(t *testing.T) {
 	// Create test regex objects
 	testRegex1, _ := regexp.Compile("hello")
@@ -1520,7 +2263,7 @@ func TestReplaceBuiltin(t *testing.T) {
 		},
 		{
 			args:     []Object{&String{Value: "text"}, &Regex{Pattern: "test", Regexp: testRegex1}, &Integer{Value: 123}},
-			expected: "third argument to `replace` must be STRING, got INTEGER",
+			expected: "third argument to `replace` must be STRING or FUNCTION, got INTEGER",
 		},
 		// Error case: nil arguments
 		{
@@ -1543,7 +2286,7 @@ func TestReplaceBuiltin(t *testing.T) {
 	}
 
 	for i, tt := range tests {
-		result := replaceBuiltin.Fn(tt.args...)
+		result := replaceBuiltin.Apply(nil, tt.args...)
 
 		switch expected := tt.expected.(type) {
 		case string:
@@ -1777,8 +2520,8 @@ func TestJSONParseBuiltin(t *testing.T) {
 				t.Errorf("object is not Hash. got=%T (%+v)", result, result)
 				continue
 			}
-			if len(hash.Pairs) != len(expected) {
-				t.Errorf("hash has wrong length. got=%d, want=%d", len(hash.Pairs), len(expected))
+			if hash.Len() != len(expected) {
+				t.Errorf("hash has wrong length. got=%d, want=%d", hash.Len(), len(expected))
 			}
 		}
 	}
@@ -1819,18 +2562,15 @@ func TestJSONStringifyBuiltin(t *testing.T) {
 }
 
 func TestJSONStringifyWithIndent(t *testing.T) {
-	hash := &Hash{
-		Pairs: map[HashKey]HashPair{
-			(&String{Value: "name"}).HashKey(): {
-				Key:   &String{Value: "name"},
-				Value: &String{Value: "test"},
-			},
-			(&String{Value: "value"}).HashKey(): {
-				Key:   &String{Value: "value"},
-				Value: NewInteger(42),
-			},
-		},
-	}
+	hash := NewHash()
+	hash.Set((&String{Value: "name"}).HashKey(), HashPair{
+		Key:   &String{Value: "name"},
+		Value: &String{Value: "test"},
+	})
+	hash.Set((&String{Value: "value"}).HashKey(), HashPair{
+		Key:   &String{Value: "value"},
+		Value: NewInteger(42),
+	})
 
 	builtin := GetBuiltinByName("json_stringify")
 	if builtin == nil {
@@ -1870,3 +2610,212 @@ func TestJSONParseInvalidJSON(t *testing.T) {
 		t.Errorf("error message should contain 'invalid JSON'. got=%q", errObj.Message)
 	}
 }
+
+func TestFormat(t *testing.T) {
+	big, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+
+	tests := []struct {
+		format   string
+		args     []Object
+		expected string
+		err      string
+	}{
+		{"%d|%5d|%-3d|%03d", []Object{NewInteger(7), NewInteger(42), NewInteger(1), NewInteger(5)}, "7|   42|1  |005", ""},
+		{"%b %o %x %X %c", []Object{NewInteger(5), NewInteger(8), NewInteger(255), NewInteger(255), NewInteger(65)}, "101 10 ff FF A", ""},
+		{"%d", []Object{NewBigInt(big)}, "123456789012345678901234567890", ""},
+		{"%.2f %e %g", []Object{&Float{Value: 3.14159}, NewInteger(1500), &Float{Value: 0.5}}, "3.14 1.500000e+03 0.5", ""},
+		{"%s and %v", []Object{&String{Value: "a"}, &Array{Elements: []Object{NewInteger(1), &String{Value: "b"}}}}, "a and [1, b]", ""},
+		{"%q %t %x", []Object{&String{Value: "hi"}, TRUE, &Bytes{Value: []byte{1, 171}}}, `"hi" true 01ab`, ""},
+		{"100%%", nil, "100%", ""},
+		{"%d", []Object{&String{Value: "1"}}, "", "%d: expected INTEGER, got STRING"},
+		{"%c", []Object{NewBigInt(big)}, "", "%c: integer out of range"},
+		{"%d %d", []Object{NewInteger(1)}, "", "missing argument for %d"},
+		{"%d", []Object{NewInteger(1), NewInteger(2)}, "", "too many arguments: format uses 1, got 2"},
+		{"%5", []Object{NewInteger(1)}, "", `incomplete directive "%5" at end of format`},
+		{"%y", []Object{NewInteger(1)}, "", "%y: unknown verb %y"},
+	}
+
+	for _, tt := range tests {
+		result, err := Format(tt.format, tt.args)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("Format(%q): wrong error. want=%q, got=%v", tt.format, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Format(%q): unexpected error: %s", tt.format, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("Format(%q): want=%q, got=%q", tt.format, tt.expected, result)
+		}
+	}
+}
+
+func TestMergeHashes(t *testing.T) {
+	hashOf := func(pairs ...Object) *Hash {
+		hash := NewHash()
+		for i := 0; i < len(pairs); i += 2 {
+			key, _ := HashKeyOf(pairs[i])
+			hash.Set(key, HashPair{Key: pairs[i], Value: pairs[i+1]})
+		}
+		return hash
+	}
+	str := func(s string) Object { return &String{Value: s} }
+
+	base := hashOf(
+		str("a"), NewInteger(1),
+		str("nested"), hashOf(str("x"), NewInteger(1), str("y"), NewInteger(2)),
+		str("b"), NewInteger(2),
+	)
+	other := hashOf(
+		str("c"), NewInteger(3),
+		str("nested"), hashOf(str("y"), NewInteger(20), str("z"), NewInteger(30)),
+		str("a"), NewInteger(10),
+	)
+
+	shallow := MergeHashes(base, other, false)
+	if shallow.Inspect() != "{a: 10, nested: {y: 20, z: 30}, b: 2, c: 3}" {
+		t.Errorf("wrong shallow merge. got=%s", shallow.Inspect())
+	}
+	deep := MergeHashes(base, other, true)
+	if deep.Inspect() != "{a: 10, nested: {x: 1, y: 20, z: 30}, b: 2, c: 3}" {
+		t.Errorf("wrong deep merge. got=%s", deep.Inspect())
+	}
+
+	// A hash only merges into a hash; other values are replaced
+	replaced := MergeHashes(base, hashOf(str("a"), hashOf(str("k"), TRUE)), true)
+	if replaced.Inspect() != "{a: {k: true}, nested: {x: 1, y: 2}, b: 2}" {
+		t.Errorf("wrong merge of a hash into an integer. got=%s", replaced.Inspect())
+	}
+
+	if base.Inspect() != "{a: 1, nested: {x: 1, y: 2}, b: 2}" {
+		t.Errorf("merging modified the base hash. got=%s", base.Inspect())
+	}
+	if other.Inspect() != "{c: 3, nested: {y: 20, z: 30}, a: 10}" {
+		t.Errorf("merging modified the other hash. got=%s", other.Inspect())
+	}
+}
+
+func TestRepr(t *testing.T) {
+	point := NewStructType("Point", []string{"x", "y"})
+	set := NewSet()
+	set.Add(&String{Value: "s"})
+	hash := NewHash()
+	for _, pair := range []HashPair{
+		{Key: &String{Value: "a"}, Value: NewInteger(1)},
+		{Key: NewInteger(2), Value: &String{Value: "b"}},
+	} {
+		key, _ := HashKeyOf(pair.Key)
+		hash.Set(key, pair)
+	}
+
+	tests := []struct {
+		value    Object
+		expected string
+	}{
+		{&String{Value: "1"}, `"1"`},
+		{NewInteger(1), "1"},
+		{&String{Value: "a \"quoted\"\nline"}, `"a \"quoted\"\nline"`},
+		{&Array{Elements: []Object{NewInteger(1), &String{Value: "1"}, NULL}}, `[1, "1", null]`},
+		{hash, `{"a": 1, 2: "b"}`},
+		{set, `#{"s"}`},
+		{point.Construct([]Object{&String{Value: "x"}, &Float{Value: 2}}), `Point{x: "x", y: 2.0}`},
+		{&Array{Elements: []Object{&Array{Elements: []Object{&String{Value: ""}}}}}, `[[""]]`},
+	}
+
+	for _, tt := range tests {
+		if result := Repr(tt.value); result != tt.expected {
+			t.Errorf("Repr(%s): want=%s, got=%s", tt.value.Inspect(), tt.expected, result)
+		}
+	}
+}
+
+func TestToInteger(t *testing.T) {
+	tests := []struct {
+		value    Object
+		base     int
+		expected string
+		err      string
+	}{
+		{NewInteger(5), 10, "5", ""},
+		{&Float{Value: 2.9}, 10, "2", ""},
+		{&Float{Value: -2.9}, 10, "-2", ""},
+		{&String{Value: "-42"}, 10, "-42", ""},
+		{&String{Value: "+7"}, 10, "7", ""},
+		{&String{Value: "ff"}, 16, "255", ""},
+		{&String{Value: "101"}, 2, "5", ""},
+		{&String{Value: "123456789012345678901234567890"}, 10, "123456789012345678901234567890", ""},
+		{&String{Value: " 1"}, 10, "", `cannot parse " 1" as INTEGER`},
+		{&String{Value: "1.5"}, 10, "", `cannot parse "1.5" as INTEGER`},
+		{&String{Value: "12"}, 2, "", `cannot parse "12" as INTEGER`},
+		{&Float{Value: math.NaN()}, 10, "", ""},
+		{TRUE, 10, "", "cannot convert BOOLEAN to INTEGER"},
+	}
+
+	for _, tt := range tests {
+		result, err := ToInteger(tt.value, tt.base)
+		switch {
+		case tt.expected == "" && tt.err == "":
+			if err == nil {
+				t.Errorf("ToInteger(%s): expected an error, got %s", tt.value.Inspect(), result.Inspect())
+			}
+		case tt.err != "":
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("ToInteger(%s): wrong error. want=%q, got=%v", tt.value.Inspect(), tt.err, err)
+			}
+		case err != nil:
+			t.Errorf("ToInteger(%s): unexpected error: %s", tt.value.Inspect(), err)
+		case !IsInteger(result) || result.Inspect() != tt.expected:
+			t.Errorf("ToInteger(%s): want=%s, got=%s (%T)", tt.value.Inspect(), tt.expected, result.Inspect(), result)
+		}
+	}
+
+	// Integers in the int64 range are plain Integers however they were made
+	if result, _ := ToInteger(&String{Value: "12"}, 10); result.Type() != INTEGER_OBJ {
+		t.Errorf("small parsed integer is not an Integer")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	defaults := csvOptions{delimiter: ',', infer: true}
+
+	tests := []struct {
+		text     string
+		options  func(csvOptions) csvOptions
+		expected string
+		err      string
+	}{
+		{"a,b\n1,2.5\n", nil, `[["a", "b"], [1, 2.5]]`, ""},
+		{"x,1e5,0.10,-0,,true\n", nil, `[["x", "1e5", "0.10", "-0", null, true]]`, ""},
+		{"1,2\n3\n", nil, `[[1, 2], [3]]`, ""},
+		{"1,2\n", func(o csvOptions) csvOptions { o.infer = false; return o }, `[["1", "2"]]`, ""},
+		{"a;b\n1;\n", func(o csvOptions) csvOptions { o.delimiter = ';'; o.header = true; return o }, `[{"a": 1, "b": null}]`, ""},
+		{"a,b\n1\n", func(o csvOptions) csvOptions { o.header = true; return o }, "", "record on line 2: wrong number of fields"},
+		{"a,a\n", func(o csvOptions) csvOptions { o.header = true; return o }, "", `duplicate column "a" in header`},
+		{"a,\"b\n", nil, "", "parse error on line 1, column 6: extraneous or missing \" in quoted-field"},
+		{"a,b\"c\"\n", func(o csvOptions) csvOptions { o.lazyQuotes = true; return o }, `[["a", "b\"c\""]]`, ""},
+	}
+
+	for _, tt := range tests {
+		options := defaults
+		if tt.options != nil {
+			options = tt.options(options)
+		}
+		result, err := parseCSV(tt.text, options)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parseCSV(%q): wrong error. want=%q, got=%v", tt.text, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCSV(%q): unexpected error: %s", tt.text, err)
+			continue
+		}
+		if Repr(result) != tt.expected {
+			t.Errorf("parseCSV(%q): want=%s, got=%s", tt.text, tt.expected, Repr(result))
+		}
+	}
+}
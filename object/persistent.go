@@ -0,0 +1,206 @@
+package object
+
+import "math/bits"
+
+// Arrays and hashes are immutable values: push, rest and pop return a new
+// collection and leave their argument unchanged. To keep that cheap the new
+// collection shares structure with the old one instead of copying it.
+
+// appendClaim is shared by every slice that views the same backing array.
+// free is the capacity left after the furthest element any of them has
+// written, so exactly one slice, the one ending there, may append in place.
+// Any other slice ending earlier would overwrite an element that is still
+// visible through another value and has to copy instead.
+type appendClaim struct {
+	free int
+}
+
+// appendShared returns s with x appended. It writes into the backing array
+// of s when s owns the claim and copies into a larger one otherwise, so a
+// sequence of appends takes amortised O(1) time per element.
+func appendShared[T any](s []T, claim *appendClaim, x T) ([]T, *appendClaim) {
+	free := cap(s) - len(s)
+	if claim != nil && free > 0 && free == claim.free {
+		claim.free--
+		s = s[:len(s)+1]
+		s[len(s)-1] = x
+		return s, claim
+	}
+
+	grown := make([]T, len(s)+1, 2*len(s)+4)
+	copy(grown, s)
+	grown[len(s)] = x
+	return grown, &appendClaim{free: cap(grown) - len(grown)}
+}
+
+// Push returns a new array with el appended.
+func (ao *Array) Push(el Object) *Array {
+	elements, claim := appendShared(ao.Elements, ao.claim, el)
+	return &Array{Elements: elements, claim: claim}
+}
+
+// Rest returns a new array without the first element, sharing the rest.
+func (ao *Array) Rest() *Array {
+	return &Array{Elements: ao.Elements[1:], claim: ao.claim}
+}
+
+// Pop returns a new array without the last element, sharing the rest.
+func (ao *Array) Pop() *Array {
+	return &Array{Elements: ao.Elements[:len(ao.Elements)-1], claim: ao.claim}
+}
+
+// hamtNode is a node of the hash array mapped trie that backs Hash. Each
+// level consumes hamtBits bits of HashKey.Value; bitmap records which of
+// the 32 possible slots are present so that slots holds only those, in
+// order. Updates copy the path from the root to the changed slot and share
+// everything else.
+type hamtNode struct {
+	bitmap uint32
+	slots  []hamtSlot
+}
+
+// hamtSlot holds either a child node or the entries that hash to it. More
+// than one entry only occurs once all bits of the hash are used up, i.e.
+// for keys with equal Value but different Type.
+type hamtSlot struct {
+	child   *hamtNode
+	entries []hashEntry
+}
+
+type hashEntry struct {
+	key  HashKey
+	pair HashPair
+	// seq is the position of key in the insertion order of its Hash
+	seq int
+}
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+func hamtIndex(bitmap uint32, bit uint32) int {
+	return bits.OnesCount32(bitmap & (bit - 1))
+}
+
+func (n *hamtNode) get(key HashKey, shift uint) (hashEntry, bool) {
+	for n != nil {
+		bit := uint32(1) << ((key.Value >> shift) & hamtMask)
+		if n.bitmap&bit == 0 {
+			return hashEntry{}, false
+		}
+		slot := n.slots[hamtIndex(n.bitmap, bit)]
+		if slot.child == nil {
+			for _, entry := range slot.entries {
+				if entry.key == key {
+					return entry, true
+				}
+			}
+			return hashEntry{}, false
+		}
+		n, shift = slot.child, shift+hamtBits
+	}
+	return hashEntry{}, false
+}
+
+// put returns a copy of n with entry added or replaced. added is false
+// when an entry for the key already existed.
+func (n *hamtNode) put(entry hashEntry, shift uint) (node *hamtNode, added bool) {
+	if n == nil {
+		n = &hamtNode{}
+	}
+	bit := uint32(1) << ((entry.key.Value >> shift) & hamtMask)
+	i := hamtIndex(n.bitmap, bit)
+
+	if n.bitmap&bit == 0 {
+		slots := make([]hamtSlot, len(n.slots)+1)
+		copy(slots, n.slots[:i])
+		slots[i] = hamtSlot{entries: []hashEntry{entry}}
+		copy(slots[i+1:], n.slots[i:])
+		return &hamtNode{bitmap: n.bitmap | bit, slots: slots}, true
+	}
+
+	slot := n.slots[i]
+	switch {
+	case slot.child != nil:
+		slot.child, added = slot.child.put(entry, shift+hamtBits)
+	case slot.entries[0].key.Value == entry.key.Value && shift+hamtBits >= 64:
+		slot.entries, added = putEntry(slot.entries, entry)
+	default:
+		existing := slot.entries[0]
+		if len(slot.entries) == 1 && existing.key == entry.key {
+			slot.entries = []hashEntry{entry}
+			break
+		}
+		// Push the existing entries one level down and retry there
+		var child *hamtNode
+		for _, e := range slot.entries {
+			child, _ = child.put(e, shift+hamtBits)
+		}
+		slot = hamtSlot{}
+		slot.child, added = child.put(entry, shift+hamtBits)
+	}
+
+	slots := make([]hamtSlot, len(n.slots))
+	copy(slots, n.slots)
+	slots[i] = slot
+	return &hamtNode{bitmap: n.bitmap, slots: slots}, added
+}
+
+func putEntry(entries []hashEntry, entry hashEntry) ([]hashEntry, bool) {
+	result := make([]hashEntry, len(entries), len(entries)+1)
+	copy(result, entries)
+	for i, e := range result {
+		if e.key == entry.key {
+			result[i] = entry
+			return result, false
+		}
+	}
+	return append(result, entry), true
+}
+
+// remove returns a copy of n without the entry for key, or nil if that
+// leaves n empty. removed is false if there was no such entry.
+func (n *hamtNode) remove(key HashKey, shift uint) (node *hamtNode, removed bool) {
+	if n == nil {
+		return nil, false
+	}
+	bit := uint32(1) << ((key.Value >> shift) & hamtMask)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	i := hamtIndex(n.bitmap, bit)
+
+	slot := n.slots[i]
+	if slot.child != nil {
+		slot.child, removed = slot.child.remove(key, shift+hamtBits)
+	} else {
+		entries := make([]hashEntry, 0, len(slot.entries))
+		for _, e := range slot.entries {
+			if e.key == key {
+				removed = true
+				continue
+			}
+			entries = append(entries, e)
+		}
+		slot.entries = entries
+	}
+	if !removed {
+		return n, false
+	}
+
+	if slot.child == nil && len(slot.entries) == 0 {
+		if len(n.slots) == 1 {
+			return nil, true
+		}
+		slots := make([]hamtSlot, len(n.slots)-1)
+		copy(slots, n.slots[:i])
+		copy(slots[i:], n.slots[i+1:])
+		return &hamtNode{bitmap: n.bitmap &^ bit, slots: slots}, true
+	}
+
+	slots := make([]hamtSlot, len(n.slots))
+	copy(slots, n.slots)
+	slots[i] = slot
+	return &hamtNode{bitmap: n.bitmap, slots: slots}, true
+}
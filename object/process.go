@@ -0,0 +1,70 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ExitError is the error the engines stop with when a script calls exit().
+// The builtin never ends the process itself; the program embedding the
+// engine decides what to do with Code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// NewExit returns the error value that makes a script exit with code.
+func NewExit(code int) *Error {
+	exit := &ExitError{Code: code}
+	return &Error{Message: exit.Error(), Exit: exit}
+}
+
+func (h *Host) args() []string {
+	if h.Args == nil {
+		return os.Args[1:]
+	}
+	return h.Args
+}
+
+// lookupEnv returns the environment variable called name.
+func (h *Host) lookupEnv(name string) (string, bool) {
+	if h.Env == nil {
+		return os.LookupEnv(name)
+	}
+	value, ok := h.Env[name]
+	return value, ok
+}
+
+// environment returns all environment variables as a hash sorted by name.
+func (h *Host) environment() *Hash {
+	env := h.Env
+	if env == nil {
+		env = map[string]string{}
+		for _, entry := range os.Environ() {
+			if name, value, ok := strings.Cut(entry, "="); ok {
+				env[name] = value
+			}
+		}
+	}
+
+	hash := NewHash()
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: &String{Value: env[name]}})
+	}
+	return hash
+}
+
+func (h *Host) stderr() io.Writer {
+	if h.Stderr == nil {
+		return os.Stderr
+	}
+	return h.Stderr
+}
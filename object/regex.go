@@ -0,0 +1,121 @@
+package object
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// regexFlags lists the flags accepted by NewRegex in the order Inspect
+// shows them: i makes matching case-insensitive, m lets ^ and $ match at
+// line breaks and s lets . match a newline.
+const regexFlags = "ims"
+
+// NewRegex compiles pattern with a combination of the flags i, m and s.
+func NewRegex(pattern, flags string) (*Regex, error) {
+	for _, flag := range flags {
+		if !strings.ContainsRune(regexFlags, flag) {
+			return nil, fmt.Errorf("unknown regex flag: %c", flag)
+		}
+	}
+
+	var normalized strings.Builder
+	for _, flag := range regexFlags {
+		if strings.ContainsRune(flags, flag) {
+			normalized.WriteRune(flag)
+		}
+	}
+
+	source := pattern
+	if normalized.Len() > 0 {
+		source = "(?" + normalized.String() + ")" + pattern
+	}
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Regex{Pattern: pattern, Flags: normalized.String(), Regexp: re}, nil
+}
+
+// matchGroups returns the whole match followed by each capture group for
+// a match located by FindStringSubmatchIndex. Groups that did not take
+// part in the match are NULL.
+func matchGroups(text string, loc []int) *Array {
+	elements := make([]Object, len(loc)/2)
+	for i := range elements {
+		elements[i] = groupValue(text, loc, i)
+	}
+	return &Array{Elements: elements}
+}
+
+// namedGroups returns the named capture groups of a match as a hash from
+// group name to value.
+func namedGroups(re *regexp.Regexp, text string, loc []int) *Hash {
+	hash := NewHash()
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		key := &String{Value: name}
+		hash.Set(key.HashKey(), HashPair{Key: key, Value: groupValue(text, loc, i)})
+	}
+	return hash
+}
+
+func groupValue(text string, loc []int, i int) Object {
+	if loc[2*i] < 0 {
+		return NULL
+	}
+	return &String{Value: text[loc[2*i]:loc[2*i+1]]}
+}
+
+// codePointCounter converts increasing byte offsets into text to code
+// point offsets, the unit strings are indexed and sliced in.
+type codePointCounter struct {
+	text       string
+	byteOffset int
+	codePoints int
+}
+
+func (c *codePointCounter) offset(byteOffset int) int {
+	if byteOffset < c.byteOffset {
+		c.byteOffset, c.codePoints = 0, 0
+	}
+	c.codePoints += utf8.RuneCountInString(c.text[c.byteOffset:byteOffset])
+	c.byteOffset = byteOffset
+	return c.codePoints
+}
+
+// ReplaceRegex replaces every match of re in text. Each replacement is
+// either a template, in which $1 and ${name} refer to capture groups and
+// $$ is a literal $, or the result of calling fn with the array of the
+// match and its groups, which must be a string.
+func ReplaceRegex(caller Caller, text string, re *regexp.Regexp, replacement Object) Object {
+	template, isTemplate := replacement.(*String)
+
+	var out strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(text[last:loc[0]])
+		last = loc[1]
+
+		if isTemplate {
+			out.Write(re.ExpandString(nil, template.Value, text, loc))
+			continue
+		}
+
+		result := caller.Call(replacement, matchGroups(text, loc))
+		switch result := result.(type) {
+		case *Error:
+			return result
+		case *String:
+			out.WriteString(result.Value)
+		default:
+			return newError("replacement function must return STRING, got %s", TypeName(result))
+		}
+	}
+	out.WriteString(text[last:])
+
+	return &String{Value: out.String()}
+}
@@ -0,0 +1,55 @@
+package object
+
+import (
+	"cmp"
+	"slices"
+)
+
+// sortedValues returns the values of iterator in a new slice, stably sorted
+// by compare. The first error returned by compare stops the comparisons
+// that call back into functions and is returned instead.
+func sortedValues(iterator Iterator, compare func(a, b Object) (int, *Error)) ([]Object, *Error) {
+	values := []Object{}
+	for {
+		value, ok, err := nextValue(iterator)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+
+	var sortErr *Error
+	slices.SortStableFunc(values, func(a, b Object) int {
+		if sortErr != nil {
+			return 0
+		}
+		result, err := compare(a, b)
+		if err != nil {
+			sortErr = err
+		}
+		return result
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return values, nil
+}
+
+// comparatorResult converts the result of a comparator passed to sort into
+// the sign of an integer.
+func comparatorResult(result Object) (int, *Error) {
+	switch result := result.(type) {
+	case *Error:
+		return 0, result
+	case *Integer:
+		return cmp.Compare(result.Value, 0), nil
+	case *BigInt:
+		return result.Value.Sign(), nil
+	default:
+		return 0, newError("comparator passed to `sort` must return INTEGER, got %s",
+			TypeName(result))
+	}
+}
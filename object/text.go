@@ -0,0 +1,157 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Strings are sequences of Unicode code points: len counts code points and
+// indexing and slicing address them, not the bytes of the UTF-8 encoding.
+
+// StringLength returns the number of code points in str.
+func StringLength(str string) int {
+	return utf8.RuneCountInString(str)
+}
+
+// StringIndex returns the code point at index i of str as a string, or NULL
+// if i is out of range.
+func StringIndex(str string, i int64) Object {
+	if i < 0 {
+		return NULL
+	}
+	for _, r := range str {
+		if i == 0 {
+			return &String{Value: string(r)}
+		}
+		i--
+	}
+	return NULL
+}
+
+// runeOffsets returns the byte offsets of the code points lo and hi of str.
+func runeOffsets(str string, lo, hi int) (int, int) {
+	start, end := len(str), len(str)
+	n := 0
+	for offset := range str {
+		if n == lo {
+			start = offset
+		}
+		if n == hi {
+			end = offset
+			break
+		}
+		n++
+	}
+	return start, end
+}
+
+// Graphemes splits str into user-perceived characters: a base character
+// together with the combining marks, variation selectors and emoji
+// modifiers that follow it, emoji joined by zero width joiners, pairs of
+// regional indicators forming a flag, Hangul syllables built from jamo, and
+// CR LF. This covers the extended grapheme cluster rules of UAX #29 that
+// matter in practice without the full property tables.
+func Graphemes(str string) []string {
+	clusters := []string{}
+	start := 0
+	var prev rune
+	regionalCount := 0
+
+	for offset, r := range str {
+		if offset > 0 && graphemeBreak(prev, r, regionalCount) {
+			clusters = append(clusters, str[start:offset])
+			start = offset
+			regionalCount = 0
+		}
+		if isRegionalIndicator(r) {
+			regionalCount++
+		}
+		prev = r
+	}
+	if start < len(str) {
+		clusters = append(clusters, str[start:])
+	}
+	return clusters
+}
+
+const zeroWidthJoiner = '\u200d'
+
+// graphemeBreak reports whether there is a cluster boundary between prev
+// and r. regionalCount is the number of regional indicators in the current
+// cluster.
+func graphemeBreak(prev, r rune, regionalCount int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return false
+	case unicode.IsControl(prev) || unicode.IsControl(r):
+		return true
+	case hangulContinues(prev, r):
+		return false
+	case isExtend(r) || r == zeroWidthJoiner:
+		return false
+	case prev == zeroWidthJoiner && isPictographic(r):
+		return false
+	case isRegionalIndicator(prev) && isRegionalIndicator(r):
+		return regionalCount%2 == 0
+	default:
+		return true
+	}
+}
+
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		unicode.Is(unicode.Variation_Selector, r) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) // emoji skin tone modifiers
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+func isPictographic(r rune) bool {
+	return unicode.Is(unicode.So, r) || (r >= 0x1f000 && r <= 0x1faff) || (r >= 0x2600 && r <= 0x27bf)
+}
+
+// Hangul jamo: leading consonants (L), vowels (V) and trailing consonants
+// (T), and precomposed syllables with (LVT) and without (LV) a trailing
+// consonant.
+func hangulContinues(prev, r rune) bool {
+	isL := func(r rune) bool { return r >= 0x1100 && r <= 0x115f || r >= 0xa960 && r <= 0xa97c }
+	isV := func(r rune) bool { return r >= 0x1160 && r <= 0x11a7 || r >= 0xd7b0 && r <= 0xd7c6 }
+	isT := func(r rune) bool { return r >= 0x11a8 && r <= 0x11ff || r >= 0xd7cb && r <= 0xd7fb }
+	isSyllable := r >= 0xac00 && r <= 0xd7a3
+	prevIsLV := prev >= 0xac00 && prev <= 0xd7a3 && (prev-0xac00)%28 == 0
+	prevIsLVT := prev >= 0xac00 && prev <= 0xd7a3 && !prevIsLV
+
+	switch {
+	case isL(prev):
+		return isL(r) || isV(r) || isSyllable
+	case prevIsLV || isV(prev):
+		return isV(r) || isT(r)
+	case prevIsLVT || isT(prev):
+		return isT(r)
+	default:
+		return false
+	}
+}
+
+// Normalize converts str to one of the Unicode normalization forms NFC,
+// NFD, NFKC and NFKD.
+func Normalize(str, form string) (string, error) {
+	switch strings.ToUpper(form) {
+	case "NFC":
+		return norm.NFC.String(str), nil
+	case "NFD":
+		return norm.NFD.String(str), nil
+	case "NFKC":
+		return norm.NFKC.String(str), nil
+	case "NFKD":
+		return norm.NFKD.String(str), nil
+	default:
+		return "", fmt.Errorf("unknown normalization form: %s", form)
+	}
+}
@@ -0,0 +1,202 @@
+package object
+
+import (
+	"cmp"
+	"fmt"
+	"time"
+	_ "time/tzdata" // time zones must not depend on the host's zoneinfo
+)
+
+// Time is an instant together with the time zone it is displayed in.
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() ObjectType { return TIME_OBJ }
+func (t *Time) Inspect() string  { return t.Value.Format(time.RFC3339Nano) }
+
+// HashKey hashes the instant, so equal times in different zones are the
+// same key, as they are ==.
+func (t *Time) HashKey() HashKey {
+	return HashKey{Type: t.Type(), Value: uint64(t.Value.UnixNano())}
+}
+
+func (t *Time) Member(name string) (Object, bool) {
+	v := t.Value
+	switch name {
+	case "year":
+		return NewInteger(int64(v.Year())), true
+	case "month":
+		return NewInteger(int64(v.Month())), true
+	case "day":
+		return NewInteger(int64(v.Day())), true
+	case "hour":
+		return NewInteger(int64(v.Hour())), true
+	case "minute":
+		return NewInteger(int64(v.Minute())), true
+	case "second":
+		return NewInteger(int64(v.Second())), true
+	case "nanosecond":
+		return NewInteger(int64(v.Nanosecond())), true
+	case "weekday":
+		return &String{Value: v.Weekday().String()}, true
+	case "yearday":
+		return NewInteger(int64(v.YearDay())), true
+	case "unix":
+		return NewInteger(v.Unix()), true
+	case "unix_ms":
+		return NewInteger(v.UnixMilli()), true
+	case "zone":
+		return &String{Value: v.Location().String()}, true
+	default:
+		return nil, false
+	}
+}
+
+// Duration is the elapsed time between two instants.
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) Type() ObjectType { return DURATION_OBJ }
+func (d *Duration) Inspect() string  { return d.Value.String() }
+
+func (d *Duration) HashKey() HashKey {
+	return HashKey{Type: d.Type(), Value: uint64(d.Value)}
+}
+
+func (d *Duration) Member(name string) (Object, bool) {
+	switch name {
+	case "hours":
+		return &Float{Value: d.Value.Hours()}, true
+	case "minutes":
+		return &Float{Value: d.Value.Minutes()}, true
+	case "seconds":
+		return &Float{Value: d.Value.Seconds()}, true
+	case "milliseconds":
+		return NewInteger(d.Value.Milliseconds()), true
+	case "nanoseconds":
+		return NewInteger(int64(d.Value)), true
+	default:
+		return nil, false
+	}
+}
+
+// namedLayouts lets scripts refer to the common layouts by name instead of
+// spelling out the reference time.
+var namedLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"RFC822":      time.RFC822,
+	"RFC1123":     time.RFC1123,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+}
+
+// Layout resolves a layout name, or returns layout itself as a Go
+// reference-time layout such as "2006-01-02 15:04".
+func Layout(layout string) string {
+	if named, ok := namedLayouts[layout]; ok {
+		return named
+	}
+	return layout
+}
+
+// IsTimeValue reports whether obj is a Time or a Duration.
+func IsTimeValue(obj Object) bool {
+	switch obj.(type) {
+	case *Time, *Duration:
+		return true
+	default:
+		return false
+	}
+}
+
+// TimeArithmetic applies +, -, * or / where at least one operand is a
+// Time or Duration: times move by durations, the difference of two times is
+// a duration, and durations scale by numbers.
+func TimeArithmetic(operator string, left, right Object) (Object, error) {
+	switch l := left.(type) {
+	case *Time:
+		switch r := right.(type) {
+		case *Duration:
+			switch operator {
+			case "+":
+				return &Time{Value: l.Value.Add(r.Value)}, nil
+			case "-":
+				return &Time{Value: l.Value.Add(-r.Value)}, nil
+			}
+		case *Time:
+			if operator == "-" {
+				return &Duration{Value: l.Value.Sub(r.Value)}, nil
+			}
+		}
+	case *Duration:
+		switch r := right.(type) {
+		case *Time:
+			if operator == "+" {
+				return &Time{Value: r.Value.Add(l.Value)}, nil
+			}
+		case *Duration:
+			switch operator {
+			case "+":
+				return &Duration{Value: l.Value + r.Value}, nil
+			case "-":
+				return &Duration{Value: l.Value - r.Value}, nil
+			case "/":
+				if r.Value == 0 {
+					return nil, errDivisionByZero
+				}
+				return &Float{Value: float64(l.Value) / float64(r.Value)}, nil
+			}
+		default:
+			if factor, ok := durationFactor(right); ok {
+				switch operator {
+				case "*":
+					return &Duration{Value: time.Duration(float64(l.Value) * factor)}, nil
+				case "/":
+					if factor == 0 {
+						return nil, errDivisionByZero
+					}
+					return &Duration{Value: time.Duration(float64(l.Value) / factor)}, nil
+				}
+			}
+		}
+	default:
+		if r, ok := right.(*Duration); ok && operator == "*" {
+			if factor, ok := durationFactor(left); ok {
+				return &Duration{Value: time.Duration(float64(r.Value) * factor)}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unknown operator: %s %s %s", TypeName(left), operator, TypeName(right))
+}
+
+func durationFactor(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+func compareTimes(left, right Object) (int, bool) {
+	switch l := left.(type) {
+	case *Time:
+		if r, ok := right.(*Time); ok {
+			return l.Value.Compare(r.Value), true
+		}
+	case *Duration:
+		if r, ok := right.(*Duration); ok {
+			return cmp.Compare(l.Value, r.Value), true
+		}
+	}
+	return 0, false
+}
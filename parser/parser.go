@@ -1,12 +1,13 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
-	"strings"
 )
 
 const (
@@ -34,12 +35,14 @@ var precedences = map[token.TokenType]int{
 	token.GT:       LESSGREATER,
 	token.LTE:      LESSGREATER,
 	token.GTE:      LESSGREATER,
+	token.IN:       LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type (
@@ -55,6 +58,10 @@ type Parser struct {
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// yields records, for each function literal being parsed, whether a
+	// yield statement has been seen in its body
+	yields []bool
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -75,8 +82,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.BYTES, p.parseBytesLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.SET_LBRACE, p.parseSetLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -91,8 +100,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GTE, p.parseInfixExpression)
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -143,6 +154,12 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseBreakStatement()
 	case token.CONTINUE:
 		return p.parseContinueStatement()
+	case token.YIELD:
+		return p.parseYieldStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.IMPL:
+		return p.parseImplStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -213,6 +230,11 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: bigValue}
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
 		p.errors = append(p.errors, msg)
@@ -229,28 +251,14 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 
 	lit := &ast.FloatLiteral{Token: p.curToken}
 
-	// Check if the token contains a dot
-	if containsDot := strings.Contains(p.curToken.Literal, "."); containsDot {
-		value, err := strconv.ParseFloat(p.curToken.Literal, 64)
-		if err != nil {
-			msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-			p.errors = append(p.errors, msg)
-			return nil
-		}
-
-		lit.Value = value
-		return lit
-	}
-
-	// If there's no dot, treat it as an integer and convert
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
 		p.errors = append(p.errors, msg)
 		return nil
 	}
 
-	lit.Value = float64(value)
+	lit.Value = value
 	return lit
 }
 
@@ -432,13 +440,26 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	if p.parseFunctionRest(lit) == nil {
+		return nil
+	}
+
+	return lit
+}
+
+// parseFunctionRest parses the parameters and body of a function literal
+// whose opening parenthesis is the current token.
+func (p *Parser) parseFunctionRest(lit *ast.FunctionLiteral) *ast.FunctionLiteral {
 	lit.Parameters = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	p.yields = append(p.yields, false)
 	lit.Body = p.parseBlockStatement()
+	lit.IsGenerator = p.yields[len(p.yields)-1]
+	p.yields = p.yields[:len(p.yields)-1]
 
 	return lit
 }
@@ -504,17 +525,99 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseBytesLiteral() ast.Expression {
+	value, err := unescapeBytes(p.curToken.Literal)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse b%q as bytes: %s", p.curToken.Literal, err)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	return &ast.BytesLiteral{Token: p.curToken, Value: value}
+}
+
+// unescapeBytes decodes the escapes \xHH, \n, \r, \t, \0, \\ and \" of
+// a bytes literal. Any other character stands for its UTF-8 encoding.
+func unescapeBytes(literal string) ([]byte, error) {
+	value := make([]byte, 0, len(literal))
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '\\' {
+			value = append(value, literal[i])
+			continue
+		}
+		i++
+		if i == len(literal) {
+			return nil, errors.New("unterminated escape")
+		}
+		switch literal[i] {
+		case 'n':
+			value = append(value, '\n')
+		case 'r':
+			value = append(value, '\r')
+		case 't':
+			value = append(value, '\t')
+		case '0':
+			value = append(value, 0)
+		case '\\', '"':
+			value = append(value, literal[i])
+		case 'x':
+			if i+3 > len(literal) {
+				return nil, errors.New("\\x needs two hex digits")
+			}
+			b, err := strconv.ParseUint(literal[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, errors.New("\\x needs two hex digits")
+			}
+			value = append(value, byte(b))
+			i += 2
+		default:
+			return nil, fmt.Errorf("unknown escape \\%c", literal[i])
+		}
+	}
+	return value, nil
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
 	return array
 }
 
+func (p *Parser) parseSetLiteral() ast.Expression {
+	set := &ast.SetLiteral{Token: p.curToken}
+	set.Elements = p.parseExpressionList(token.RBRACE)
+	return set
+}
+
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
 
+// parseSliceExpression parses the rest of left[start:end] after start,
+// with the current token on start or on '[' if start was omitted.
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -523,6 +626,120 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Fields = []*ast.Identifier{}
+	seen := make(map[string]bool)
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			p.errors = append(p.errors, fmt.Sprintf("duplicate field %s in struct %s", field.Value, stmt.Name.Value))
+			// Skip the rest of the declaration, which is not worth a
+			// second error
+			for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+				p.nextToken()
+			}
+			return nil
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImplStatement() *ast.ImplStatement {
+	stmt := &ast.ImplStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Methods = []*ast.FunctionLiteral{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.FUNCTION) {
+			return nil
+		}
+
+		method := &ast.FunctionLiteral{Token: p.curToken}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		method.Name = p.curToken.Literal
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		if p.parseFunctionRest(method) == nil {
+			return nil
+		}
+		stmt.Methods = append(stmt.Methods, method)
+
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -539,6 +756,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -584,7 +802,7 @@ func (p *Parser) parseAssignmentExpression() ast.Expression {
 	return assignExpr
 }
 
-func (p *Parser) parseForStatement() *ast.ForStatement {
+func (p *Parser) parseForStatement() ast.Statement {
 	stmt := &ast.ForStatement{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
@@ -594,6 +812,9 @@ func (p *Parser) parseForStatement() *ast.ForStatement {
 	// Parse initialization (optional)
 	if !p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IN) {
+			return p.parseForInStatement(stmt.Token)
+		}
 		stmt.Init = p.parseStatement()
 		// Skip semicolon if it exists after the statement
 		if p.peekTokenIs(token.SEMICOLON) {
@@ -636,6 +857,29 @@ func (p *Parser) parseForStatement() *ast.ForStatement {
 	return stmt
 }
 
+// parseForInStatement parses `for (x in iterable) { ... }` once the
+// loop variable is the current token.
+func (p *Parser) parseForInStatement(forToken token.Token) *ast.ForInStatement {
+	stmt := &ast.ForInStatement{Token: forToken}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.nextToken() // consume 'in'
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseBlockStatement()
+
+	return stmt
+}
+
 func (p *Parser) parseWhileStatement() *ast.WhileStatement {
 	stmt := &ast.WhileStatement{Token: p.curToken}
 
@@ -678,3 +922,23 @@ func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
 
 	return stmt
 }
+
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.curToken}
+
+	if len(p.yields) == 0 {
+		p.errors = append(p.errors, "yield outside of function")
+	} else {
+		p.yields[len(p.yields)-1] = true
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
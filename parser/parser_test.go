@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
@@ -696,6 +697,95 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingSetLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#{1, 2 * 2, 3}", "#{1, (2 * 2), 3}"},
+		{"#{}", "#{}"},
+		{"x in #{1} == true", "((x in #{1}) == true)"},
+		{"a + 1 in b && c", "(((a + 1) in b) && c)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestBytesLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []byte
+	}{
+		{`b"hi"`, []byte("hi")},
+		{`b"\x00\xffA"`, []byte{0, 0xff, 'A'}},
+		{`b"\"\\\n"`, []byte{'"', '\\', '\n'}},
+		{`b""`, []byte{}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.BytesLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BytesLiteral. got=%T", stmt.Expression)
+		}
+		if !bytes.Equal(literal.Value, tt.expected) {
+			t.Errorf("literal.Value not %v. got=%v", tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+
+	for _, input := range []string{`b"\x4"`, `b"\q"`, `b"\xzz"`} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %s", input)
+		}
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:n - 1]", "(a[:(n - 1)])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[1:2][0]", "((a[1:2])[0])"},
+		{"{a[:1]: 2}", "{(a[:1]) 2}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
 func TestParsingIndexExpressions(t *testing.T) {
 	input := "myArray[1 + 1]"
 
@@ -952,6 +1042,7 @@ func TestLoopStatements(t *testing.T) {
 	}{
 		{"for (let i = 0; i < 3; i += 1) { puts(i); }", "*ast.ForStatement"},
 		{"while (x > 0) { x -= 1; }", "*ast.WhileStatement"},
+		{"for (x in [1, 2]) { puts(x); }", "*ast.ForInStatement"},
 		{"break;", "*ast.BreakStatement"},
 		{"continue;", "*ast.ContinueStatement"},
 	}
@@ -973,3 +1064,269 @@ func TestLoopStatements(t *testing.T) {
 		}
 	}
 }
+
+func TestForInStatement(t *testing.T) {
+	input := `for (item in items) { puts(item); }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForInStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+	if !testIdentifier(t, stmt.Iterable, "items") {
+		return
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statement. got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestYieldStatements(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedGenerator []bool
+	}{
+		{"fn() { yield 1; }", []bool{true}},
+		{"fn() { 1 }", []bool{false}},
+		{"fn() { if (true) { yield 1; } }", []bool{true}},
+		{"fn() { fn() { yield 1; } }", []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+		for i, expected := range tt.expectedGenerator {
+			if function.IsGenerator != expected {
+				t.Errorf("%q: function %d IsGenerator wrong. want=%t, got=%t",
+					tt.input, i, expected, function.IsGenerator)
+			}
+			if i+1 < len(tt.expectedGenerator) {
+				inner := function.Body.Statements[0].(*ast.ExpressionStatement)
+				function = inner.Expression.(*ast.FunctionLiteral)
+			}
+		}
+	}
+
+	p := New(lexer.New("yield 1;"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "yield outside of function" {
+		t.Errorf("expected yield outside of function error, got=%q", p.Errors())
+	}
+}
+
+func TestStructStatement(t *testing.T) {
+	input := `struct Point { x, y }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.StructStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "Point") {
+		return
+	}
+	if len(stmt.Fields) != 2 {
+		t.Fatalf("struct has wrong number of fields. want=2, got=%d", len(stmt.Fields))
+	}
+	testIdentifier(t, stmt.Fields[0], "x")
+	testIdentifier(t, stmt.Fields[1], "y")
+
+	p = New(lexer.New(`struct Point { x, x, y } Point`))
+	program = p.ParseProgram()
+	if len(p.Errors()) != 1 || p.Errors()[0] != "duplicate field x in struct Point" {
+		t.Errorf("expected only the duplicate field error, got=%q", p.Errors())
+	}
+	last := program.Statements[len(program.Statements)-1]
+	if expr, ok := last.(*ast.ExpressionStatement); !ok || expr.String() != "Point" {
+		t.Errorf("parsing did not continue after the struct. got=%T", last)
+	}
+}
+
+func TestImplStatement(t *testing.T) {
+	input := `impl Point {
+	fn norm(self) { self.x * self.x }
+	fn scale(self, k) { Point(self.x * k) }
+}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ImplStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ImplStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "Point") {
+		return
+	}
+
+	tests := []struct {
+		name   string
+		params []string
+	}{
+		{"norm", []string{"self"}},
+		{"scale", []string{"self", "k"}},
+	}
+
+	if len(stmt.Methods) != len(tests) {
+		t.Fatalf("impl has wrong number of methods. want=%d, got=%d",
+			len(tests), len(stmt.Methods))
+	}
+	for i, tt := range tests {
+		method := stmt.Methods[i]
+		if method.Name != tt.name {
+			t.Errorf("method %d has wrong name. want=%q, got=%q", i, tt.name, method.Name)
+		}
+		if len(method.Parameters) != len(tt.params) {
+			t.Fatalf("method %s has wrong parameters. want=%d, got=%d",
+				tt.name, len(tt.params), len(method.Parameters))
+		}
+		for j, param := range tt.params {
+			testIdentifier(t, method.Parameters[j], param)
+		}
+	}
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"p.x", "(p.x)"},
+		{"p.x.y", "((p.x).y)"},
+		{"p.norm()", "(p.norm)()"},
+		{"a.b + c.d * 2", "((a.b) + ((c.d) * 2))"},
+		{"-p.x", "(-(p.x))"},
+		{"xs[0].y", "((xs[0]).y)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "92233720368547758070;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.BigIntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value.String() != "92233720368547758070" {
+		t.Errorf("literal.Value not %s. got=%s", "92233720368547758070", literal.Value)
+	}
+	if literal.TokenLiteral() != "92233720368547758070" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "92233720368547758070",
+			literal.TokenLiteral())
+	}
+}
+
+func TestNumericLiteralForms(t *testing.T) {
+	integers := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xff", 255},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_dead_beef", 0xdeadbeef},
+	}
+
+	for _, tt := range integers {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %d. got=%d", tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("literal.String() not %q. got=%q", tt.input, literal.String())
+		}
+	}
+
+	floats := []struct {
+		input    string
+		expected float64
+	}{
+		{"1e9", 1e9},
+		{"2.5E-3", 0.0025},
+		{"6e+2", 600},
+		{"1_000.000_1", 1000.0001},
+	}
+
+	for _, tt := range floats {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+
+	big := New(lexer.New("0xffff_ffff_ffff_ffff_ff"))
+	program := big.ParseProgram()
+	checkParserErrors(t, big)
+	if _, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.BigIntegerLiteral); !ok {
+		t.Errorf("large hex literal is not a BigIntegerLiteral")
+	}
+
+	for _, input := range []string{"0b12", "0o8", "1__0", "1_", "0x", "1e400"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %s", input)
+		}
+	}
+}
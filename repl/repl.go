@@ -2,6 +2,7 @@ package repl
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	// "monkey/evaluator"
@@ -9,6 +10,7 @@ import (
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 
 	"monkey/compiler"
 	"monkey/vm"
@@ -16,8 +18,25 @@ import (
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+// Start reads lines from in and runs them with a copy of host, or of an
+// empty host if it is nil, whose standard input is the rest of in. The REPL
+// and read_line() share one buffer, so neither loses the input the other
+// has read ahead. Start returns the exit code of the session, which is the
+// argument of exit() if a script called it and 0 otherwise.
+func Start(in io.Reader, out io.Writer, host *object.Host) int {
+	input := bufio.NewReader(in)
+	if host == nil {
+		host = &object.Host{}
+	}
+	host = &object.Host{
+		Now:       host.Now,
+		FileRoots: host.FileRoots,
+		Random:    host.Random,
+		Stdin:     input,
+		Stderr:    host.Stderr,
+		Args:      host.Args,
+		Env:       host.Env,
+	}
 	// env := object.NewEnvironment()
 	constants := []object.Object{}
 	globals := make([]object.Object, vm.GlobalsSize)
@@ -25,19 +44,21 @@ func Start(in io.Reader, out io.Writer) {
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
+	for i, v := range object.BuiltinValues {
+		symbolTable.DefineBuiltinValue(i, v.Name)
+	}
 
 	for {
 		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		line, err := input.ReadString('\n')
+		if err != nil && line == "" {
+			return 0
 		}
-
-		line := scanner.Text()
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
 
 		if line == "exit" {
-			fmt.Println("bye!")
-			return
+			fmt.Fprintln(out, "bye!")
+			return 0
 		}
 		l := lexer.New(line)
 		p := parser.New(l)
@@ -58,7 +79,7 @@ func Start(in io.Reader, out io.Writer) {
 		// io.WriteString(out, "\n")
 
 		comp := compiler.NewWithState(symbolTable, constants)
-		err := comp.Compile(program)
+		err = comp.Compile(program)
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Compilation failed:\n %s\n", err)
 			continue
@@ -66,7 +87,13 @@ func Start(in io.Reader, out io.Writer) {
 		code := comp.Bytecode()
 		constants = code.Constants
 		machine := vm.NewWithGlobalsStore(code, globals)
+		machine.SetHost(host)
 		err = machine.Run()
+		var exit *object.ExitError
+		if errors.As(err, &exit) {
+			fmt.Fprintln(out, "bye!")
+			return exit.Code
+		}
 		if err != nil {
 			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
 			continue
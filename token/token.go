@@ -31,6 +31,7 @@ const (
 	// デリミタ
 	COMMA     = ","
 	SEMICOLON = ";"
+	DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -52,6 +53,14 @@ const (
 	WHILE    = "WHILE"
 	BREAK    = "BREAK"
 	CONTINUE = "CONTINUE"
+	IN       = "IN"
+
+	// ジェネレータキーワード
+	YIELD = "YIELD"
+
+	// 構造体キーワード
+	STRUCT = "STRUCT"
+	IMPL   = "IMPL"
 
 	// 代入演算子
 	PLUS_ASSIGN     = "+="
@@ -60,10 +69,13 @@ const (
 	SLASH_ASSIGN    = "/="
 
 	STRING = "STRING"
+	BYTES  = "BYTES" // b"\x00\xff"
 
 	LBRACKET = "["
 	RBRACKET = "]"
 
+	SET_LBRACE = "#{"
+
 	COLON = ":"
 
 	// コメント
@@ -82,6 +94,10 @@ var keywords = map[string]TokenType{
 	"while":    WHILE,
 	"break":    BREAK,
 	"continue": CONTINUE,
+	"in":       IN,
+	"yield":    YIELD,
+	"struct":   STRUCT,
+	"impl":     IMPL,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"monkey/object"
+)
+
+// callGenerator replaces a call of a generator function and its arguments
+// on the stack with a new generator. The function body does not run until
+// the generator is resumed.
+func (vm *VM) callGenerator(closure *object.Closure, numArgs int) error {
+	g, err := vm.newGeneratorVM(closure, vm.stack[vm.sp-numArgs:vm.sp])
+	if err != nil {
+		return err
+	}
+	vm.sp = vm.sp - numArgs - 1
+	return vm.push(object.NewGenerator(g.resume, nil))
+}
+
+// newGeneratorVM creates the VM that runs a generator body. It shares the
+// constants and globals of vm but owns the generator's frame and stack, so
+// both survive while the generator is suspended at a yield.
+func (vm *VM) newGeneratorVM(closure *object.Closure, args []object.Object) (*VM, error) {
+	baseFn := &object.CompiledFunction{}
+	g := &VM{
+		constants: vm.constants,
+		globals:   vm.globals,
+		host:      vm.host,
+
+		stack:    make([]object.Object, InitialStackSize),
+		stackCap: InitialStackSize,
+		frames:   make([]*Frame, MaxFrames),
+	}
+
+	// The bottom frame has no instructions, so Run returns once the
+	// generator body returns to it.
+	g.frames[0] = NewFrame(&object.Closure{Fn: baseFn}, 0)
+	g.stack[0] = closure
+	copy(g.stack[1:], args)
+
+	frame := NewFrame(closure, 1)
+	if err := g.ensureStack(frame.basePointer + closure.Fn.NumLocals); err != nil {
+		return nil, err
+	}
+	g.frames[1] = frame
+	g.frameIndex = 2
+	g.sp = frame.basePointer + closure.Fn.NumLocals
+
+	return g, nil
+}
+
+// resume runs the generator body up to its next yield. It reports false
+// once the body has returned.
+func (vm *VM) resume() (object.Object, bool) {
+	vm.yielded = nil
+	if err := vm.Run(); err != nil {
+		return errorObject(err), true
+	}
+	if vm.yielded == nil {
+		return nil, false
+	}
+	return vm.yielded, true
+}
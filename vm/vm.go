@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"monkey/code"
 	"monkey/compiler"
@@ -25,22 +26,39 @@ type VM struct {
 
 	frames     []*Frame
 	frameIndex int
+
+	// yielded holds the value of the last OpYield while a generator's VM
+	// is suspended
+	yielded object.Object
+
+	// host overrides the host installed with object.SetHost for the
+	// builtins this VM runs
+	host *object.Host
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		NumLocals:    bytecode.NumLocals,
+	}
 	mainClosure := &object.Closure{Fn: mainFn}
 	mainFrame := NewFrame(mainClosure, 0)
 	frames := make([]*Frame, MaxFrames)
 	frames[0] = mainFrame
 
+	// Locals of top-level blocks live at the bottom of the stack
+	stackSize := InitialStackSize
+	for stackSize <= mainFn.NumLocals {
+		stackSize *= 2
+	}
+
 	return &VM{
 		constants:    bytecode.Constants,
 		instructions: bytecode.Instructions,
 
-		stack:      make([]object.Object, InitialStackSize),
-		stackCap:   InitialStackSize,
-		sp:         0,
+		stack:      make([]object.Object, stackSize),
+		stackCap:   stackSize,
+		sp:         mainFn.NumLocals,
 		globals:    make([]object.Object, GlobalsSize),
 		frames:     frames,
 		frameIndex: 1,
@@ -53,6 +71,17 @@ func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	return vm
 }
 
+// SetHost makes the builtins run by vm use h instead of the host installed
+// with object.SetHost.
+func (vm *VM) SetHost(h *object.Host) {
+	vm.host = h
+}
+
+// Host returns the host set with SetHost, or nil.
+func (vm *VM) Host() *object.Host {
+	return vm.host
+}
+
 func (vm *VM) StackTop() object.Object {
 	if vm.sp == 0 {
 		return nil
@@ -171,6 +200,44 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpSet:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			set, err := vm.buildSet(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+			err = vm.push(set)
+			if err != nil {
+				return err
+			}
+		case code.OpIn:
+			container := vm.pop()
+			item := vm.pop()
+
+			found, ok := object.Contains(container, item)
+			if !ok {
+				return fmt.Errorf("unknown operator: %s in %s", object.TypeName(item), object.TypeName(container))
+			}
+			err := vm.push(nativeBoolToBooleanObject(found))
+			if err != nil {
+				return err
+			}
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			result, err := object.Slice(left, start, end)
+			if err != nil {
+				return err
+			}
+			err = vm.push(result)
+			if err != nil {
+				return err
+			}
 		case code.OpHash:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -200,6 +267,14 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpTailCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			err := vm.executeTailCall(int(numArgs))
+			if err != nil {
+				return err
+			}
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 
@@ -257,12 +332,75 @@ func (vm *VM) Run() error {
 			if err != nil {
 				return err
 			}
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.FreeVariables[freeIndex] = vm.pop()
 		case code.OpCurrentClosure:
 			currentClosure := vm.currentFrame().cl
 			err := vm.push(currentClosure)
 			if err != nil {
 				return err
 			}
+		case code.OpYield:
+			vm.yielded = vm.pop()
+			return nil
+		case code.OpIter:
+			iterable := vm.pop()
+			iterator, ok := object.Iterate(iterable)
+			if !ok {
+				return fmt.Errorf("cannot iterate over %s", object.TypeName(iterable))
+			}
+			err := vm.push(iterator)
+			if err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterator := vm.stack[vm.sp-1].(object.Iterator)
+			value, ok := iterator.Next()
+			if !ok {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+			if errObj, isError := value.(*object.Error); isError {
+				return runtimeError(errObj)
+			}
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+		case code.OpGetMember:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.constants[nameIndex].(*object.String).Value
+			err := vm.executeMemberExpression(vm.pop(), name)
+			if err != nil {
+				return err
+			}
+		case code.OpStruct:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			template := vm.constants[constIndex].(*object.StructType)
+			err := vm.push(object.NewStructType(template.Name, template.Fields))
+			if err != nil {
+				return err
+			}
+		case code.OpImpl:
+			numMethods := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+
+			err := vm.executeImpl(numMethods)
+			if err != nil {
+				return err
+			}
 		case code.OpPop:
 			vm.pop()
 		}
@@ -281,6 +419,16 @@ func (vm *VM) push(o object.Object) error {
 	return nil
 }
 
+// ensureStack grows the stack until it can hold size elements
+func (vm *VM) ensureStack(size int) error {
+	for size > vm.stackCap {
+		if err := vm.growStack(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // growStack doubles the stack size up to MaxStackSize
 func (vm *VM) growStack() error {
 	newCap := vm.stackCap * 2
@@ -314,46 +462,69 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	leftType := left.Type()
 	rightType := right.Type()
 	switch {
-	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
+	case object.IsInteger(left) && object.IsInteger(right):
 		return vm.executeBinaryIntegerOperation(op, left, right)
 	case leftType == object.FLOAT_OBJ && rightType == object.FLOAT_OBJ:
 		return vm.executeBinaryFloatOperation(op, left, right)
-	case leftType == object.INTEGER_OBJ && rightType == object.FLOAT_OBJ:
-		convertedLeft := &object.Float{Value: float64(left.(*object.Integer).Value)}
+	case object.IsInteger(left) && rightType == object.FLOAT_OBJ:
+		convertedLeft := &object.Float{Value: object.IntegerToFloat(left)}
 		return vm.executeBinaryFloatOperation(op, convertedLeft, right)
-	case leftType == object.FLOAT_OBJ && rightType == object.INTEGER_OBJ:
-		convertedRight := &object.Float{Value: float64(right.(*object.Integer).Value)}
+	case leftType == object.FLOAT_OBJ && object.IsInteger(right):
+		convertedRight := &object.Float{Value: object.IntegerToFloat(right)}
 		return vm.executeBinaryFloatOperation(op, left, convertedRight)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
+	case object.IsTimeValue(left) || object.IsTimeValue(right):
+		return vm.executeBinaryTimeOperation(op, left, right)
 	default:
-		return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
+		return fmt.Errorf("unsupported types for binary operation: %s %s", object.TypeName(left), object.TypeName(right))
 	}
 }
 
+func (vm *VM) executeBinaryTimeOperation(op code.Opcode, left, right object.Object) error {
+	operator, ok := arithmeticOperators[op]
+	if !ok {
+		return fmt.Errorf("unknown operator: %d (%s %s)", op, object.TypeName(left), object.TypeName(right))
+	}
+
+	result, err := object.TimeArithmetic(operator, left, right)
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
+}
+
+var arithmeticOperators = map[code.Opcode]string{
+	code.OpAdd: "+",
+	code.OpSub: "-",
+	code.OpMul: "*",
+	code.OpDiv: "/",
+}
+
 func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
 	if left == nil || right == nil {
 		return fmt.Errorf("nil operand in binary integer operation")
 	}
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
 
-	var result int64
+	var operator string
 	switch op {
 	case code.OpAdd:
-		result = leftValue + rightValue
+		operator = "+"
 	case code.OpSub:
-		result = leftValue - rightValue
+		operator = "-"
 	case code.OpMul:
-		result = leftValue * rightValue
+		operator = "*"
 	case code.OpDiv:
-		result = leftValue / rightValue
+		operator = "/"
 	default:
 		return fmt.Errorf("unknown operator: %d", op)
 	}
 
-	vm.push(object.NewInteger(result))
-	return nil
+	result, err := object.IntegerArithmetic(operator, left, right)
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
 }
 
 func (vm *VM) executeBinaryFloatOperation(op code.Opcode, left, right object.Object) error {
@@ -398,40 +569,54 @@ func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Ob
 func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
-	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+	if object.IsInteger(left) && object.IsInteger(right) {
 		return vm.executeIntegerComparison(op, left, right)
 	}
-	switch op {
-	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
-	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
-	default:
-		return fmt.Errorf("unknown operator: %d (%s %s)", op, left.Type(), right.Type())
+
+	operator, ok := comparisonOperators[op]
+	if !ok {
+		return fmt.Errorf("unknown operator: %d (%s %s)", op, object.TypeName(left), object.TypeName(right))
+	}
+
+	result, ok := object.Compare(operator, left, right)
+	if !ok {
+		if left.Type() != right.Type() {
+			return fmt.Errorf("type mismatch: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
+		}
+		return fmt.Errorf("unknown operator: %s %s %s", object.TypeName(left), operator, object.TypeName(right))
 	}
+	return vm.push(nativeBoolToBooleanObject(result))
+}
+
+var comparisonOperators = map[code.Opcode]string{
+	code.OpEqual:            "==",
+	code.OpNotEqual:         "!=",
+	code.OpGreaterThan:      ">",
+	code.OpLessThan:         "<",
+	code.OpGreaterThanEqual: ">=",
+	code.OpLessThanEqual:    "<=",
 }
 
 func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
 	if left == nil || right == nil {
 		return fmt.Errorf("nil operand in integer comparison")
 	}
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+	cmp := object.CompareIntegers(left, right)
 
 	var result bool
 	switch op {
 	case code.OpEqual:
-		result = leftValue == rightValue
+		result = cmp == 0
 	case code.OpNotEqual:
-		result = leftValue != rightValue
+		result = cmp != 0
 	case code.OpGreaterThan:
-		result = leftValue > rightValue
+		result = cmp > 0
 	case code.OpLessThan:
-		result = leftValue < rightValue
+		result = cmp < 0
 	case code.OpGreaterThanEqual:
-		result = leftValue >= rightValue
+		result = cmp >= 0
 	case code.OpLessThanEqual:
-		result = leftValue <= rightValue
+		result = cmp <= 0
 	default:
 		return fmt.Errorf("unknown operator: %d", op)
 	}
@@ -458,21 +643,30 @@ func (vm *VM) executeMinusOperator() error {
 	if operand == nil {
 		return fmt.Errorf("nil operand in minus operation")
 	}
-	if operand.Type() != object.INTEGER_OBJ {
-		return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+	if float, ok := operand.(*object.Float); ok {
+		return vm.push(&object.Float{Value: -float.Value})
+	}
+	if !object.IsInteger(operand) {
+		return fmt.Errorf("unsupported type for negation: %s", object.TypeName(operand))
 	}
-	value := operand.(*object.Integer).Value
-	return vm.push(object.NewInteger(-value))
+	return vm.push(object.NegateInteger(operand))
 }
 
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.push(object.StringIndex(left.(*object.String).Value, index.(*object.Integer).Value))
+	case left.Type() == object.BYTES_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeBytesIndex(left, index)
+	case index.Type() == object.BIGINT_OBJ && object.IsSequence(left):
+		// A big integer lies outside every array, string and bytes value
+		return vm.push(object.NULL)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
 	default:
-		return fmt.Errorf("index operator not supported: %s", left.Type())
+		return fmt.Errorf("index operator not supported: %s", object.TypeName(left))
 	}
 }
 
@@ -485,21 +679,31 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 	for i := startIndex; i < endIndex; i += 2 {
 		key := vm.stack[i]
 		value := vm.stack[i+1]
 
 		pair := object.HashPair{Key: key, Value: value}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.HashKeyOf(key)
 		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			return nil, fmt.Errorf("unusable as hash key: %s", object.TypeName(key))
 		}
 
-		pairs[hashKey.HashKey()] = pair
+		hash.Set(hashKey, pair)
+	}
+	return hash, nil
+}
+
+func (vm *VM) buildSet(startIndex, endIndex int) (object.Object, error) {
+	set := object.NewSet()
+	for i := startIndex; i < endIndex; i++ {
+		if !set.Add(vm.stack[i]) {
+			return nil, fmt.Errorf("unusable as set element: %s", object.TypeName(vm.stack[i]))
+		}
 	}
-	return &object.Hash{Pairs: pairs}, nil
+	return set, nil
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -533,16 +737,25 @@ func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	return vm.push(arrayObject.Elements[i])
 }
 
+func (vm *VM) executeBytesIndex(data, index object.Object) error {
+	value := data.(*object.Bytes).Value
+	i := index.(*object.Integer).Value
+	if i < 0 || i >= int64(len(value)) {
+		return vm.push(object.NULL)
+	}
+	return vm.push(object.NewInteger(int64(value[i])))
+}
+
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
 	if hash == nil || index == nil {
 		return fmt.Errorf("nil operand in hash index operation")
 	}
 	hashObject := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashKeyOf(index)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", index.Type())
+		return fmt.Errorf("unusable as hash key: %s", object.TypeName(index))
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return vm.push(object.NULL)
 	}
@@ -556,9 +769,13 @@ func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.frameIndex-1]
 }
 
-func (vm *VM) pushFrame(f *Frame) {
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.frameIndex >= MaxFrames {
+		return fmt.Errorf("maximum call depth exceeded")
+	}
 	vm.frames[vm.frameIndex] = f
 	vm.frameIndex++
+	return nil
 }
 
 func (vm *VM) popFrame() *Frame {
@@ -587,14 +804,84 @@ func (vm *VM) executeCall(numArgs int) error {
 		return vm.callClosure(callee, numArgs)
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
+	case *object.StructType:
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		result := callee.Construct(args)
+		vm.sp = vm.sp - numArgs - 1
+		return vm.push(result)
+	case *object.BoundMethod:
+		return vm.callBoundMethod(callee, numArgs)
 	default:
 		return fmt.Errorf("calling non-function and non-builtin")
 	}
 }
 
+// callBoundMethod calls the method of a bound method with the receiver as
+// its first argument.
+func (vm *VM) callBoundMethod(method *object.BoundMethod, numArgs int) error {
+	if err := vm.unbindMethod(method, numArgs); err != nil {
+		return err
+	}
+	return vm.executeCall(numArgs + 1)
+}
+
+// unbindMethod replaces the bound method on the stack with its method and
+// inserts the receiver as the first argument.
+func (vm *VM) unbindMethod(method *object.BoundMethod, numArgs int) error {
+	if err := vm.ensureStack(vm.sp + 1); err != nil {
+		return err
+	}
+
+	calleeIndex := vm.sp - 1 - numArgs
+	copy(vm.stack[calleeIndex+2:], vm.stack[calleeIndex+1:vm.sp])
+	vm.stack[calleeIndex] = method.Method
+	vm.stack[calleeIndex+1] = method.Receiver
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) executeMemberExpression(obj object.Object, name string) error {
+	accessor, ok := obj.(object.Member)
+	if !ok {
+		return fmt.Errorf("member access not supported: %s", object.TypeName(obj))
+	}
+
+	member, ok := accessor.Member(name)
+	if !ok {
+		return fmt.Errorf("unknown member %s on %s", name, object.ValueType(obj))
+	}
+
+	return vm.push(member)
+}
+
+// executeImpl attaches the name and method pairs above the struct on the
+// stack to that struct.
+func (vm *VM) executeImpl(numMethods int) error {
+	base := vm.sp - 2*numMethods - 1
+	target := vm.stack[base]
+
+	structType, ok := target.(*object.StructType)
+	if !ok {
+		return fmt.Errorf("cannot impl %s: not a struct", object.TypeName(target))
+	}
+
+	for i := base + 1; i < vm.sp; i += 2 {
+		name := vm.stack[i].(*object.String).Value
+		if !structType.DefineMethod(name, vm.stack[i+1]) {
+			return fmt.Errorf("method %s conflicts with a field of %s", name, structType.Name)
+		}
+	}
+
+	vm.sp = base
+	return nil
+}
+
 func (vm *VM) callBuiltin(fn *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	result := fn.Fn(args...)
+	result := fn.Apply(vm, args...)
+	if err, ok := result.(*object.Error); ok && (err.Exit != nil || err.Fatal) {
+		return runtimeError(err)
+	}
 	vm.sp = vm.sp - numArgs - 1
 	if result != nil {
 		vm.push(result)
@@ -604,13 +891,106 @@ func (vm *VM) callBuiltin(fn *object.Builtin, numArgs int) error {
 	return nil
 }
 
+// Call runs fn with args on top of the current stack until it returns, so
+// that builtins can call back into functions. A runtime error in fn is
+// returned as an *object.Error.
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	sp, frameIndex := vm.sp, vm.frameIndex
+
+	// Like the bottom frame of a generator, the frame below the call has
+	// no instructions, so Run returns as soon as fn returns to it.
+	if err := vm.pushFrame(NewFrame(&object.Closure{Fn: &object.CompiledFunction{}}, vm.sp)); err != nil {
+		return errorObject(err)
+	}
+	err := vm.push(fn)
+	for _, arg := range args {
+		if err == nil {
+			err = vm.push(arg)
+		}
+	}
+	if err == nil {
+		err = vm.executeCall(len(args))
+	}
+	if err == nil {
+		err = vm.Run()
+	}
+
+	var result object.Object = object.NULL
+	if err != nil {
+		result = errorObject(err)
+	} else if vm.sp > sp {
+		result = vm.stack[vm.sp-1]
+	}
+	vm.sp, vm.frameIndex = sp, frameIndex
+	return result
+}
+
+// errorObject turns an error that stopped the VM into the error value
+// handed to builtins and generator consumers. It keeps the status of an
+// exit and marks any other error fatal, so that it stops the VM that
+// receives it in turn.
+func errorObject(err error) *object.Error {
+	var exit *object.ExitError
+	if errors.As(err, &exit) {
+		return object.NewExit(exit.Code)
+	}
+	return &object.Error{Message: err.Error(), Fatal: true}
+}
+
+// runtimeError is the inverse of errorObject.
+func runtimeError(err *object.Error) error {
+	if err.Exit != nil {
+		return err.Exit
+	}
+	return errors.New(err.Message)
+}
+
 func (vm *VM) callClosure(closure *object.Closure, numArgs int) error {
 	if numArgs != closure.Fn.NumParameters {
 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", closure.Fn.NumParameters, numArgs)
 	}
+	if closure.Fn.IsGenerator {
+		return vm.callGenerator(closure, numArgs)
+	}
 
 	frame := NewFrame(closure, vm.sp-numArgs)
-	vm.pushFrame(frame)
+	if err := vm.ensureStack(frame.basePointer + closure.Fn.NumLocals); err != nil {
+		return err
+	}
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+	vm.sp = frame.basePointer + closure.Fn.NumLocals
+	return nil
+}
+
+// executeTailCall calls the function below the arguments on the stack by
+// reusing the current frame, so tail-recursive functions run in constant
+// frame and stack space.
+func (vm *VM) executeTailCall(numArgs int) error {
+	if method, ok := vm.stack[vm.sp-1-numArgs].(*object.BoundMethod); ok {
+		// A method reuses the frame like any other closure
+		if err := vm.unbindMethod(method, numArgs); err != nil {
+			return err
+		}
+		numArgs++
+	}
+	closure, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok || closure.Fn.IsGenerator {
+		return vm.executeCall(numArgs)
+	}
+	if numArgs != closure.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", closure.Fn.NumParameters, numArgs)
+	}
+
+	frame := vm.currentFrame()
+	// Move the callee and its arguments over those of the current call
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	if err := vm.ensureStack(frame.basePointer + closure.Fn.NumLocals); err != nil {
+		return err
+	}
+	frame.cl = closure
+	frame.ip = -1
 	vm.sp = frame.basePointer + closure.Fn.NumLocals
 	return nil
 }
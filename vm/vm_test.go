@@ -1,13 +1,19 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/compiler"
+	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIntegerArithmetic(t *testing.T) {
@@ -482,6 +488,60 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 	}
 }
 
+// runConformanceTests runs each test in the evaluator and in the VM and
+// checks that both produce the expected result. The VM may report an
+// expected *object.Error as a compiler or runtime error instead of a value,
+// and tests expecting errUndefined only need to fail in both engines.
+func runConformanceTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+	runHostConformanceTests(t, tests, nil)
+}
+
+// runHostConformanceTests is runConformanceTests with host installed in
+// both engines unless it is nil.
+func runHostConformanceTests(t *testing.T, tests []vmTestCase, host *object.Host) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := parse(tt.input)
+
+			env := object.NewEnvironment()
+			if host != nil {
+				env.SetHost(host)
+			}
+			evaluated := evaluator.Eval(program, env)
+			if tt.expected == errUndefined {
+				if _, ok := evaluated.(*object.Error); !ok {
+					t.Errorf("evaluator: expected error, got=%T (%+v)", evaluated, evaluated)
+				}
+			} else {
+				testExpectedObject(t, tt.expected, evaluated)
+			}
+
+			comp := compiler.New()
+			err := comp.Compile(program)
+			if err == nil {
+				vm := New(comp.Bytecode())
+				if host != nil {
+					vm.SetHost(host)
+				}
+				if err = vm.Run(); err == nil {
+					testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+					return
+				}
+			}
+			switch expected := tt.expected.(type) {
+			case *object.Error:
+				if expected != errUndefined && err.Error() != expected.Message {
+					t.Errorf("vm: wrong error message. want=%q, got=%q", expected.Message, err)
+				}
+			default:
+				t.Fatalf("vm error: %s", err)
+			}
+		})
+	}
+}
+
 func testExpectedObject(t *testing.T, expected interface{}, actual object.Object) {
 	t.Helper()
 	switch expected := expected.(type) {
@@ -495,6 +555,11 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if err != nil {
 			t.Errorf("testBooleanObject failed: %s", err)
 		}
+	case float64:
+		err := testFloatObject(expected, actual)
+		if err != nil {
+			t.Errorf("testFloatObject failed: %s", err)
+		}
 	case *object.Null:
 		if actual != object.NULL {
 			t.Errorf("object is not object.NULL. got=%T (%+v)", actual, actual)
@@ -523,11 +588,11 @@ func testExpectedObject(t *testing.T, expected interface{}, actual object.Object
 		if !ok {
 			t.Errorf("object is not Hash. got=%T (%+v)", actual, actual)
 		}
-		if len(result.Pairs) != len(expected) {
-			t.Errorf("hash has wrong num of pairs. got=%d", len(result.Pairs))
+		if result.Len() != len(expected) {
+			t.Errorf("hash has wrong num of pairs. got=%d", result.Len())
 		}
 		for expectedKey, expectedValue := range expected {
-			pair, ok := result.Pairs[expectedKey]
+			pair, ok := result.Get(expectedKey)
 			if !ok {
 				t.Errorf("no pair for given key in Pairs")
 			}
@@ -585,6 +650,10 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`rest([1, 2, 3])`, []int{2, 3}},
 		{`rest([])`, object.NULL},
 		{`push([], 1)`, []int{1}},
+		{`let a = push([1], 2); let b = push(a, 3); let c = push(a, 4); b`, []int{1, 2, 3}},
+		{`let a = push([1], 2); let b = push(a, 3); let c = push(a, 4); c`, []int{1, 2, 4}},
+		{`let a = push([1], 2); let b = push(rest(a), 3); push(a, 4)`, []int{1, 2, 4}},
+		{`let a = push([1], 2); let b = push(pop(a), 3); a`, []int{1, 2}},
 		{`push(1, 1)`,
 			&object.Error{
 				Message: "argument to `push` must be ARRAY, got INTEGER",
@@ -606,6 +675,18 @@ func testBooleanObject(expected bool, actual object.Object) error {
 	return nil
 }
 
+func testFloatObject(expected float64, actual object.Object) error {
+	result, ok := actual.(*object.Float)
+	if !ok {
+		return fmt.Errorf("object is not Float. got=%T (%+v)", actual, actual)
+	}
+	if result.Value != expected {
+		return fmt.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+	}
+
+	return nil
+}
+
 func testStringObject(expected string, actual object.Object) error {
 	result, ok := actual.(*object.String)
 	if !ok {
@@ -799,3 +880,1192 @@ func TestAssignmentOperators(t *testing.T) {
 	}
 	runVmTests(t, tests)
 }
+
+// errUndefined marks conformance cases that must fail in both engines
+// because a name is used outside of the block that defined it.
+var errUndefined = &object.Error{Message: "undefined"}
+
+func TestBlockScopingConformance(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; if (true) { let x = 2; } x", 1},
+		{"let x = 1; if (true) { let x = 2; x }", 2},
+		{"let x = 1; if (false) { 0 } else { let x = 3; x }", 3},
+		{"let x = 1; if (true) { x += 1; } x", 2},
+		{"let a = 1; if (true) { let a = 2; if (true) { let a = 3; } a }", 2},
+		{"if (true) { let y = 5; } y", errUndefined},
+		{"if (false) { 0 } else { let y = 5; } y", errUndefined},
+		{"let i = 0; while (i < 2) { let t = i; i += 1; } t", errUndefined},
+		{"for (let i = 0; i < 3; i += 1) { } i", errUndefined},
+		{"let sum = 0; let i = 0; while (i < 3) { let sq = i * i; sum += sq; i += 1; } sum", 5},
+		{"let total = 0; for (let i = 0; i < 3; i += 1) { let acc = i; total += acc; } total", 3},
+		{"let x = 1; let f = fn() { let x = 2; x }; f() + x", 3},
+		{"let x = 1; let f = fn(x) { x }; f(5); x", 1},
+		{"let f = fn() { let x = 1; if (true) { let x = 10; } x }; f()", 1},
+		{"let f = fn(a) { if (a > 0) { let b = a * 2; return b; } 0 }; f(3)", 6},
+		{"let f = fn() { if (true) { let a = 1; } let b = 2; if (true) { let c = 3; b + c } }; f()", 5},
+		{"let f = fn() { if (true) { let v = 1; } v }; f()", errUndefined},
+		{"let g = if (true) { let v = 7; fn() { v } }; g()", 7},
+		{"let g = fn() { if (true) { let v = 8; fn() { v } } }; g()()", 8},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+			let countDown = fn(x) {
+				if (x == 0) { 0 } else { countDown(x - 1) }
+			};
+			countDown(100000);
+			`,
+			expected: 0,
+		},
+		{
+			input: `
+			let loop = fn(n) {
+				if (n == 0) { return 42; }
+				return loop(n - 1);
+			};
+			loop(5000);
+			`,
+			expected: 42,
+		},
+		{
+			input: `
+			let build = fn(n, acc) {
+				if (n == 0) { acc } else { build(n - 1, push(acc, n)) }
+			};
+			let sum = fn(arr, acc) {
+				if (len(arr) == 0) { acc } else { sum(rest(arr), acc + first(arr)) }
+			};
+			sum(build(2000, []), 0);
+			`,
+			expected: 2001000,
+		},
+		{
+			input: `
+			let make = fn(step) {
+				let go = fn(n, acc) {
+					if (n == 0) { acc } else { go(n - 1, acc + step) }
+				};
+				go
+			};
+			make(2)(3000, 0);
+			`,
+			expected: 6000,
+		},
+		{
+			input: `
+			let size = fn(a) { len(a) };
+			size([1, 2, 3]);
+			`,
+			expected: 3,
+		},
+	}
+
+	runVmTests(t, tests)
+
+	// Methods called in tail position reuse the frame too, far beyond the
+	// maximum call depth
+	runConformanceTests(t, []vmTestCase{
+		{
+			input: `
+			struct Counter { step }
+			impl Counter {
+				fn count(self, n, acc) {
+					if (n == 0) { acc } else { self.count(n - 1, acc + self.step) }
+				}
+			}
+			Counter(3).count(5000, 0)
+			`,
+			expected: 15000,
+		},
+		{
+			input: `
+			struct Counter { step }
+			impl Counter {
+				fn down(self, n) {
+					if (n == 0) { return self.step; }
+					return Counter(self.step + 1).down(n - 1);
+				}
+			}
+			Counter(0).down(5000)
+			`,
+			expected: 5000,
+		},
+	})
+}
+
+func TestCallDepthExceeded(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let f = fn() { f() + 1 }; f();`,
+			"maximum call depth exceeded",
+		},
+		{
+			`
+			let build = fn(n, acc) {
+				if (n == 0) { acc } else { build(n - 1, push(acc, n)) }
+			};
+			let sum = fn(arr) {
+				if (len(arr) == 0) { 0 } else { first(arr) + sum(rest(arr)) }
+			};
+			sum(build(2000, []));
+			`,
+			"stack overflow: maximum stack size (2048) exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+			let count = fn(n) { let i = 0; while (i < n) { yield i; i += 1; } };
+			let g = count(4);
+			next(g) + next(g) + next(g) + next(g)
+			`,
+			expected: 6,
+		},
+		{"let g = fn() { yield 1; }(); next(g); next(g)", object.NULL},
+		{"let g = fn() { yield 1; }(); next(g); next(g); next(g)", object.NULL},
+		{
+			input: `
+			let count = fn(n) { let i = 0; while (i < n) { yield i; i += 1; } };
+			let sum = 0;
+			for (x in count(5)) { sum += x; }
+			sum
+			`,
+			expected: 10,
+		},
+		{
+			input: `
+			let nat = fn() { let i = 0; while (true) { yield i; i += 1; } };
+			first(rest(rest(nat())))
+			`,
+			expected: 2,
+		},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; } sum", 6},
+		{`let out = ""; for (c in "abc") { out += c; } out`, "abc"},
+		{
+			input: `
+			let scaled = fn(k) { fn(xs) { for (x in xs) { yield x * k; } } };
+			let sum = 0;
+			for (v in scaled(11)([1, 2, 3, 6])) { sum += v; }
+			sum
+			`,
+			expected: 132,
+		},
+		{
+			input: `
+			let count = fn(n) { let i = 0; while (i < n) { yield i; i += 1; } };
+			let big = fn(g) { for (x in g) { if (x > 2) { yield x; } } };
+			let sum = 0;
+			for (x in big(count(6))) { sum += x; }
+			sum
+			`,
+			expected: 12,
+		},
+		{
+			input: `
+			let once = fn() { yield 1; return 5; yield 2; };
+			let n = 0;
+			for (x in once()) { n += x; }
+			n
+			`,
+			expected: 1,
+		},
+		{"let g = fn(a, b) { yield a; yield b; }(3, 4); next(g) * next(g)", 12},
+		{"let x = 0; for (x in [1, 2]) { } x", 0},
+		{"for (x in [1, 2]) { x }", object.NULL},
+		{"let f = fn() { for (x in [1, 2]) { x } }; f()", object.NULL},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestClosureCapture(t *testing.T) {
+	tests := []vmTestCase{
+		{"let g = fn() { for (let i = 0; i < 3; i += 1) { yield fn() { i } } }; map(map(g(), fn(c) { c }), fn(c) { c() })", []int{0, 1, 2}},
+		{"let g = fn() { let i = 0; while (i < 3) { yield fn() { i }; i += 1; } }; map(map(g(), fn(c) { c }), fn(c) { c() })", []int{0, 1, 2}},
+		{"let g = fn() { for (x in [1, 2]) { yield fn() { x } } }; map(map(g(), fn(c) { c }), fn(c) { c() })", []int{1, 2}},
+		{"let f = fn(n) { let g = fn() { n }; n += 1; g() }; f(1)", 1},
+		{"if (true) { let v = 8; let k = fn() { v }; v += 1; k() }", 8},
+		{"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); inc(); [inc(), n] }; f()", []int{3, 0}},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", 3},
+		{"let f = fn() { let x = 1; let g = fn() { let h = fn() { x }; x += 10; h() }; [g(), x] }; f()", []int{1, 1}},
+		{"let f = fn() { let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(5) }; f()", 120},
+		{"let f = fn() { let g = fn() { y }; let y = 1; g() }; f()", errUndefined},
+		// Top-level variables are shared
+		{"let n = 0; let g = fn() { n }; n += 1; g()", 1},
+		{"let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n", 2},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`let g = fn() { yield 1; yield -true; }(); next(g); next(g)`, errUndefined},
+		{`let g = fn() { yield 1; -true; }; let it = g(); next(it); let r = next(it); 5`, errUndefined},
+		{`let g = fn() { yield 1; 5.x; }; let it = g(); next(it); let r = next(it); 5`, &object.Error{Message: "member access not supported: INTEGER"}},
+		{`next([1])`, &object.Error{Message: "argument to `next` must be GENERATOR, got ARRAY"}},
+		{`for (x in 5) { }`, &object.Error{Message: "cannot iterate over INTEGER"}},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestStructs(t *testing.T) {
+	tests := []vmTestCase{
+		{"struct Point { x, y } let p = Point(3, 4); p.x * 10 + p.y", 34},
+		{"struct Point { x, y } Point(1, [2]).y", []int{2}},
+		{
+			input: `
+			struct Point { x, y }
+			impl Point {
+				fn norm(self) { self.x * self.x + self.y * self.y }
+				fn add(self, other) { Point(self.x + other.x, self.y + other.y) }
+			}
+			Point(1, 2).add(Point(2, 2)).norm()
+			`,
+			expected: 25,
+		},
+		{
+			input: `
+			struct Counter { n }
+			impl Counter { fn upto(self) { let i = 0; while (i < self.n) { yield i; i += 1; } } }
+			let sum = 0;
+			for (i in Counter(5).upto()) { sum += i; }
+			sum
+			`,
+			expected: 10,
+		},
+		{"struct Box { v } impl Box { fn get(self) { self.v } } Box.get(Box(7))", 7},
+		{"struct Box { v } impl Box { fn get(self) { self.v } } let get = Box(8).get; get()", 8},
+		{
+			input: `
+			let make = fn(k) { struct Box { v } impl Box { fn scaled(self) { self.v * k } } Box };
+			make(3)(5).scaled()
+			`,
+			expected: 15,
+		},
+		{"struct Empty { } Empty().x", errUndefined},
+		{
+			input:    "struct Point { x, y } Point(1)",
+			expected: &object.Error{Message: "wrong number of arguments to Point: want=2, got=1"},
+		},
+		{"struct ARRAY { x }", &object.Error{Message: "struct name ARRAY is reserved for a built-in type"}},
+		{"let f = fn() { struct BIGINT { x } }; f()", &object.Error{Message: "struct name BIGINT is reserved for a built-in type"}},
+		{"struct Point { x } first(Point(1))", &object.Error{Message: "argument to `first` must be ARRAY, got Point"}},
+		{"struct Point { x } type(Point(1))", "Point"},
+		{"struct Array { x } len(sort([Array(1), [2], Array(3)]))", 3},
+		{"struct B { x } struct A { x } sort([B(1), A(2)])[0].x", 2},
+		{"let mk = fn() { struct T { a } T }; mk() == mk()", false},
+		{"let mk = fn() { struct T { a } T }; let T = mk(); T == T", true},
+		{
+			input: `
+			let mk = fn(k) { struct T { a } if (k) { impl T { fn f(self) { 1 } } } T };
+			mk(true)(1).f();
+			mk(false)(1).f
+			`,
+			expected: errUndefined,
+		},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"struct Point { x, y } Point(1, 2).z", "unknown member z on Point"},
+		{"5.x", "member access not supported: INTEGER"},
+		{"struct Point { x } impl Point { fn x(self) { 1 } }", "method x conflicts with a field of Point"},
+		{"let Point = 1; impl Point { fn f(self) { 1 } }", "cannot impl INTEGER: not a struct"},
+		{"struct Point { x } impl Point { fn f(self) { 1 } } Point(1).f(2)", "wrong number of arguments: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		evaluated := evaluator.Eval(program, object.NewEnvironment())
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("evaluator: expected error, got=%T (%+v)", evaluated, evaluated)
+		} else if errObj.Message != tt.expected {
+			t.Errorf("evaluator: wrong error message. want=%q, got=%q", tt.expected, errObj.Message)
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		err := vm.Run()
+		if err == nil {
+			t.Fatalf("vm: expected error but resulted in none")
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("vm: wrong error message. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []vmTestCase{
+		{"len(#{1, 2, 2, 3, 1})", 3},
+		{"len(#{})", 0},
+		{`len(#{1, 2.5, "a", [1, 2], [1, 2], true})`, 5},
+		{"2 in #{1, 2}", true},
+		{`"2" in #{1, 2}`, false},
+		{"[1] in #{1, 2}", false},
+		{`"a" in {"a": 1}`, true},
+		{`"b" in {"a": 1}`, false},
+		{"3 in [1, 2, 3]", true},
+		{`"ell" in "hello"`, true},
+		{`"xyz" in "hello"`, false},
+		{"let sum = 0; for (x in #{5, 5, 6}) { sum += x; } sum", 11},
+		{"let n = 0; for (x in #{3, 1, 3, 2}) { n *= 10; n += x; } n", 312},
+		{"len(union(#{1, 2}, #{2, 3}))", 3},
+		{"let s = intersection(#{1, 2, 3}, #{2, 3, 4}); 2 in s && 3 in s && !(1 in s)", true},
+		{"let s = difference(#{1, 2, 3}, #{2}); len(s) == 2 && 1 in s && !(2 in s)", true},
+		{"len(set([1, 1, 2]))", 2},
+		{`len(set("banana"))`, 3},
+		{
+			input:    "union(#{1}, [1])",
+			expected: &object.Error{Message: "arguments to `union` must be SET, got ARRAY"},
+		},
+		{
+			input:    "set([{}])",
+			expected: &object.Error{Message: "unusable as set element: HASH"},
+		},
+		{"#{{}}", errUndefined},
+		{"1 in 2", errUndefined},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []vmTestCase{
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"9223372036854775807 + 1 - 1 == 9223372036854775807", true},
+		{"(9223372036854775807 + 1) / 2", 4611686018427387904},
+		{"-9223372036854775808 == -9223372036854775807 - 1", true},
+		{"100000000000000000000 / 10000000000", 10000000000},
+		{"100000000000000000000 == 100000000000000000000", true},
+		{"100000000000000000000 < 100000000000000000001", true},
+		{"-(-9223372036854775807 - 1) > 0", true},
+		{"let x = 9223372036854775807; x += 10; x - 10", 9223372036854775807},
+		{"let h = {100000000000000000000: 1}; h[10000000000 * 10000000000]", 1},
+		{"abs(-99999999999999999999) == 99999999999999999999", true},
+		{"abs(-9223372036854775807 - 1) == 9223372036854775807 + 1", true},
+		{"abs(-7)", 7},
+		{"first(99999999999999999999)", &object.Error{Message: "argument to `first` must be ARRAY, got INTEGER"}},
+		{"99999999999999999999[0]", &object.Error{Message: "index operator not supported: INTEGER"}},
+		{"[1, 2][99999999999999999999]", object.NULL},
+		{`"ab"[-99999999999999999999]`, object.NULL},
+		{"exit(99999999999999999999)", &object.Error{Message: "argument to `exit`: integer out of range"}},
+		{"seed(99999999999999999999)", &object.Error{Message: "argument to `seed`: integer out of range"}},
+		{`pad_left("a", 99999999999999999999)`, &object.Error{Message: "second argument to `pad_left`: integer out of range"}},
+		{`format("%c", 99999999999999999999)`, &object.Error{Message: "format error: %c: integer out of range"}},
+		{`format("%d", 99999999999999999999)`, "99999999999999999999"},
+		{
+			input: `
+			let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };
+			fact(25) / fact(23)
+			`,
+			expected: 600,
+		},
+	}
+
+	runConformanceTests(t, tests)
+
+	program := parse("1 / 0")
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err == nil || err.Error() != "division by zero" {
+		t.Errorf("expected division by zero error, got=%v", err)
+	}
+}
+
+func TestComparisonConformance(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5 < 2.0", true},
+		{"2.0 < 1.5", false},
+		{"2.0 == 2.0", true},
+		{"2.0 != 2.0", false},
+		{"1.5 <= 1.5", true},
+		{"1.5 >= 2.5", false},
+		{"1 < 1.5", true},
+		{"2 == 2.0", true},
+		{"2.0 != 2", false},
+		{"3 >= 2.5", true},
+		{"-1.5 < 0", true},
+		{`"a" < "b"`, true},
+		{`"b" <= "a"`, false},
+		{`"abc" > "abd"`, false},
+		{`"ab" < "abc"`, true},
+		{`"" < "a"`, true},
+		{`"x" == "x"`, true},
+		{`"x" != "x"`, false},
+		{`"x" + "y" == "xy"`, true},
+		{`"1" == 1`, false},
+		{`1 != "1"`, true},
+		{"true == true", true},
+		{"true != false", true},
+		{"let nan = 0.0 / 0.0; nan == nan", false},
+		{"let nan = 0.0 / 0.0; nan != nan", true},
+		{"let nan = 0.0 / 0.0; nan < 1 || nan > 1 || nan <= 1 || nan >= 1", false},
+		{"1.5 * 100000000000000000000 > 100000000000000000000", true},
+		{"100000000000000000000 == 100000000000000000000.0", true},
+		{"[1] == [1]", true},
+		{"let a = [1]; a == a", true},
+		{`"a" < 1`, errUndefined},
+		{"true < false", errUndefined},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{"[1, 2.0] == [1.0, 2]", true},
+		{`{"a": [1]} == {"a": [1]}`, true},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{"#{1, 2} == #{2, 1}", true},
+		{"[] == {}", false},
+		{`let g = {[1, 2]: "cell"}; g[[1, 2]]`, "cell"},
+		{`let g = {[0, [1]]: "nested"}; g[[0, [1]]]`, "nested"},
+		{`{[1, 2]: "cell"}[[2, 1]]`, object.NULL},
+		{"{1.5: 1}[1.5]", 1},
+		{`{2: "x"}[2.0]`, "x"},
+		{"let n = if (false) { 1 }; {n: 1}[n]", 1},
+		{"len(#{[1, 2], [1, 2], [2, 1]})", 2},
+		{"[1, 2] in #{[1, 2]}", true},
+		{"[1, 2] in {[1, 2]: true}", true},
+		{"{[{}]: 1}", &object.Error{Message: "unusable as hash key: ARRAY"}},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestBytes(t *testing.T) {
+	tests := []vmTestCase{
+		{`len(b"ab\x00")`, 3},
+		{`b"\xff\x01"[0]`, 255},
+		{`b"ab"[2]`, object.NULL},
+		{`b"hello"[1:3] == b"el"`, true},
+		{`b"hello"[:2] == b"he"`, true},
+		{`b"hello"[3:] == b"lo"`, true},
+		{`b"hello"[4:1] == b""`, true},
+		{`[1, 2, 3, 4][1:3]`, []int{2, 3}},
+		{`[1, 2, 3][-5:10]`, []int{1, 2, 3}},
+		{`b"hello"[-2:] == b"lo"`, true},
+		{`b"hello"[:-1] == b"hell"`, true},
+		{`[1, 2, 3, 4][-3:-1]`, []int{2, 3}},
+		{`"héllo"[-4:-2]`, "él"},
+		{`[1, 2, 3][-1:-2]`, []int{}},
+		{`[1, 2, 3][99999999999999999999:]`, []int{}},
+		{`[1, 2, 3][-99999999999999999999:1]`, []int{1}},
+		{`let a = [1, 2, 3]; let b = push(a[:1], 9); a`, []int{1, 2, 3}},
+		{`b"a" == "a"`, false},
+		{`b"a" < b"b"`, true},
+		{`{b"k": 1}[b"k"]`, 1},
+		{`98 in b"abc"`, true},
+		{`b"bc" in b"abc"`, true},
+		{`let sum = 0; for (x in b"\x01\x02") { sum += x; } sum`, 3},
+		{`decode(encode("héllo"))`, "héllo"},
+		{`len(encode("héllo"))`, 6},
+		{`len(encode("héllo", "latin1"))`, 5},
+		{`decode(encode("héllo", "utf-16le"), "utf-16le")`, "héllo"},
+		{`encode("hi", "utf-16be") == b"\x00h\x00i"`, true},
+		{`decode(b"\xe9", "latin1")`, "é"},
+		{`base64_encode(b"\x00\xffhi")`, "AP9oaQ=="},
+		{`base64_encode("hello")`, "aGVsbG8="},
+		{`decode(base64_decode("aGVsbG8="))`, "hello"},
+		{`base64_decode("_-8") == b"\xff\xef"`, true},
+		{`hex_encode(b"\x00\xab")`, "00ab"},
+		{`hex_decode("00AB") == b"\x00\xab"`, true},
+		{`url_encode("a b&c=d/é")`, "a+b%26c%3Dd%2F%C3%A9"},
+		{`url_decode("a+b%26c")`, "a b&c"},
+		{`decode(b"\xff")`, &object.Error{Message: "invalid utf-8 data"}},
+		{`encode("é", "ascii")`, &object.Error{Message: "cannot encode 'é' as ascii"}},
+		{`encode("a", "ebcdic")`, &object.Error{Message: "unknown encoding: ebcdic"}},
+		{`hex_decode("0g")`, &object.Error{Message: "invalid hex: encoding/hex: invalid byte: U+0067 'g'"}},
+		{`base64_encode(1)`, &object.Error{Message: "argument to `base64_encode` must be BYTES or STRING, got INTEGER"}},
+	}
+
+	runConformanceTests(t, tests)
+
+	runVmTests(t, []vmTestCase{
+		{`json_stringify([b"hi"])`, `["aGk="]`},
+	})
+}
+
+func TestTime(t *testing.T) {
+	fixed := time.Date(2024, time.February, 29, 13, 45, 30, 0, time.UTC)
+
+	tests := []vmTestCase{
+		{`format_time(now(), "RFC3339")`, "2024-02-29T13:45:30Z"},
+		{`now().year`, 2024},
+		{`now().weekday`, "Thursday"},
+		{`format_time(time(2024, 1, 31), "DateOnly")`, "2024-01-31"},
+		{`format_time(time(2024, 1, 31, 23, 0, 0, "Asia/Tokyo"), "2006-01-02 15:04 MST")`, "2024-01-31 23:00 JST"},
+		{`format_time(parse_time("03/15/2023", "01/02/2006"), "RFC3339")`, "2023-03-15T00:00:00Z"},
+		{`parse_time("2023-03-15 10:00", "DateTime")`, &object.Error{Message: `invalid time: parsing time "2023-03-15 10:00" as "2006-01-02 15:04:05": cannot parse "" as ":"`}},
+		{`parse_time("10:00", "15:04", "America/New_York").zone`, "America/New_York"},
+		{`format_time(now() + duration("36h"), "RFC3339")`, "2024-03-02T01:45:30Z"},
+		{`format_time(now() - duration("1h30m"), "Kitchen")`, "12:15PM"},
+		{`let d = now() - time(2024, 1, 1); d.minutes`, 85785.5},
+		{`(duration("1m") * 90).minutes`, 90.0},
+		{`(2 * duration("1s")).milliseconds`, 2000},
+		{`duration("1h") / duration("15m")`, 4.0},
+		{`(duration("1h") / 4).minutes`, 15.0},
+		{`duration("1h") / 0`, &object.Error{Message: "division by zero"}},
+		{`duration("1h") - duration("15m") == duration("45m")`, true},
+		{`time(2024, 1, 1) < time(2024, 1, 2)`, true},
+		{`time(2024, 1, 1, 9, 0, 0, "Europe/Berlin") == time(2024, 1, 1, 8, 0, 0)`, true},
+		{`format_time(in_zone(time(2024, 7, 1, 12, 0, 0), "Europe/Berlin"), "15:04 MST")`, "14:00 CEST"},
+		{`{time(2024, 1, 1): "new year"}[in_zone(time(2024, 1, 1), "Asia/Tokyo")]`, "new year"},
+		{`in_zone(now(), "Mars/Olympus")`, &object.Error{Message: "unknown time zone: Mars/Olympus"}},
+		{`duration("soon")`, &object.Error{Message: `invalid duration: time: invalid duration "soon"`}},
+		{`time(2024, 5, 1) + 1`, &object.Error{Message: "unknown operator: TIME + INTEGER"}},
+		{`time(2024, 13, 40)`, &object.Error{Message: "invalid month: 13"}},
+		{`time(2024, 0, 1)`, &object.Error{Message: "invalid month: 0"}},
+		{`time(2023, 2, 29)`, &object.Error{Message: "invalid day: 29"}},
+		{`time(2024, 2, 29).day`, 29},
+		{`time(2024, 4, 31)`, &object.Error{Message: "invalid day: 31"}},
+		{`time(2024, 1, 1, 24, 0, 0)`, &object.Error{Message: "invalid hour: 24"}},
+		{`time(2024, 1, 1, 0, 60, 0)`, &object.Error{Message: "invalid minute: 60"}},
+		{`time(2024, 1, 1, 0, 0, -1)`, &object.Error{Message: "invalid second: -1"}},
+	}
+
+	// Each engine reads the clock of its own host, not the global one
+	runHostConformanceTests(t, tests, &object.Host{Now: func() time.Time { return fixed }})
+
+	runVmTests(t, []vmTestCase{
+		{`json_stringify([time(2024, 5, 1), duration("90s")])`, `["2024-05-01T00:00:00Z","1m30s"]`},
+	})
+}
+
+func TestNumericLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"0xff", 255},
+		{"0o17 + 0b1010", 25},
+		{"1_000_000", 1000000},
+		{"0x7fff_ffff_ffff_ffff + 1 == 9223372036854775808", true},
+		{"1e3 == 1000", true},
+		{"2.5e-1", 0.25},
+		{"1_0.5", 10.5},
+		{"1e-9 < 0.000000001001", true},
+	}
+
+	runConformanceTests(t, tests)
+
+	runVmTests(t, []vmTestCase{
+		{`join([0.1, 2.0, 1e-9, 1e21, 0.1 + 0.2], " ")`, "0.1 2.0 1e-9 1e+21 0.30000000000000004"},
+		{`join([1.0 / 0.0, -1.0 / 0.0, 0.0 / 0.0], " ")`, "Inf -Inf NaN"},
+		{`json_stringify([0.1, 2.0, 1e-9, 1e21])`, "[0.1,2.0,1e-9,1e+21]"},
+		{`json_stringify(0.0 / 0.0)`, &object.Error{Message: "JSON stringify error: NaN is not representable in JSON"}},
+		{`json_stringify({"x": -1.0 / 0.0})`, &object.Error{Message: "JSON stringify error: -Inf is not representable in JSON"}},
+	})
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("日本語")`, 3},
+		{`len("héllo")`, 5},
+		{`"日本語"[1]`, "本"},
+		{`"日本語"[3]`, object.NULL},
+		{`"日本語"[-1]`, object.NULL},
+		{`"日本語"[1:]`, "本語"},
+		{`"héllo wörld"[1:8]`, "éllo wö"},
+		{`let 名前 = "monkey"; 名前`, "monkey"},
+		{`[len("é"), len(chars("é"))]`, []int{2, 1}},
+		{`len(chars(decode(b"e\xcc\x81")))`, 1},
+		{`chars("ga" + decode(b"\xe3\x82\x99"))[1]`, "a\u3099"},
+		{`len(chars(decode(b"\xf0\x9f\x91\x8d\xf0\x9f\x8f\xbd!")))`, 2},
+		{`len(chars(decode(b"\xf0\x9f\x87\xaf\xf0\x9f\x87\xb5\xf0\x9f\x87\xa9\xf0\x9f\x87\xaa")))`, 2},
+		{`runes("aé")`, []int{97, 233}},
+		{`normalize(decode(b"e\xcc\x81")) == "é"`, true},
+		{`len(normalize("é", "NFD"))`, 2},
+		{`normalize("ｶ", "NFKC")`, "カ"},
+		{`normalize("x", "NFX")`, &object.Error{Message: "unknown normalization form: NFX"}},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestRegexGroups(t *testing.T) {
+	tests := []vmTestCase{
+		{`match_all(regex("\d"), "abc")`, []int{}},
+		{`named_groups(regex("(?P<year>\d+)"), "none")`, object.NULL},
+		{`replace("2024-03", regex("(?P<y>\d+)-(?P<m>\d+)"), "${m}/${y} $$1")`, "03/2024 $1"},
+		{`replace("a1b22", regex("\d+"), fn(m) { m[0] + m[0] })`, "a11b2222"},
+		{`replace("hello world", regex("(\w)(\w*)"), fn(m) { m[2] + m[1] })`, "elloh orldw"},
+		{`let f = fn(s) { let k = "!"; replace(s, regex("a"), fn(m) { m[0] + k }) + k }; f("banana")`, "ba!na!na!!"},
+		{`replace("ab", regex("\w"), fn(m) { replace(m[0], regex("."), fn(x) { x[0] + x[0] }) })`, "aabb"},
+		{`replace("ab", regex("b"), len)`, &object.Error{Message: "replacement function must return STRING, got INTEGER"}},
+		{`replace("ab", regex("b"), fn(x, y) { x })`, &object.Error{Message: "wrong number of arguments: want=2, got=1"}},
+		{`match(regex("HELLO", "i"), "Hello")[0]`, "Hello"},
+		{`match(regex("^b$", "m"), "a
+b")[0]`, "b"},
+		{`match(regex("^b$"), "a
+b")`, object.NULL},
+		{`len(match(regex("a.b", "s"), "a
+b")[0])`, 3},
+		{`regex("x", "q")`, &object.Error{Message: "invalid regex pattern: unknown regex flag: q"}},
+	}
+
+	runConformanceTests(t, tests)
+
+	runVmTests(t, []vmTestCase{
+		{`json_stringify(match_all(regex("(\w)(\d)?"), "a1b"))`, `[["a1","a","1"],["b","b",null]]`},
+		{`json_stringify(named_groups(regex("(?P<year>\d+)-(?P<month>\d+)"), "on 2024-03"))`, `{"year":"2024","month":"03"}`},
+		{`json_stringify(match_positions(regex("é+"), "caféé! é"))`, "[[3,5],[7,8]]"},
+	})
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int{2, 4, 6}},
+		{`let r = map([1], fn(x) { -true }); 5`, errUndefined},
+		{`let r = sort([2, 1], fn(a, b) { a.x }); 5`, &object.Error{Message: "member access not supported: INTEGER"}},
+		{`map([], fn(x) { x })`, []int{}},
+		{`1 + reduce([1, 2], fn(a, b) { a + b }) * 2`, 7},
+		{`map(#{1, 2}, fn(x) { x + 10 })`, []int{11, 12}},
+		{`let gen = fn() { yield 1; yield 2; }; map(gen(), fn(x) { x * x })`, []int{1, 4}},
+		{`map(["a", "b"], len)`, []int{1, 1}},
+		{`let k = 3; let f = fn(arr) { map(arr, fn(x) { x + k }) }; f([1, 2])`, []int{4, 5}},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, []int{3, 4}},
+		{`filter([1, 2, 3], fn(x) { if (x == 2) { 1 } })`, []int{2}},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, 10},
+		{`reduce([1, 2, 3], fn(acc, x) { acc * x }, 10)`, 60},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, 0},
+		{`reduce([], fn(acc, x) { acc + x })`, &object.Error{Message: "`reduce` of empty iterable with no initial value"}},
+		{`let total = fn(h) { reduce(h, fn(acc, k) { acc + h[k] }, 0) }; total({"a": 1, "b": 2})`, 3},
+		{`each([1, 2], fn(x) { x })`, object.NULL},
+		{`find([1, 5, 7], fn(x) { x > 4 })`, 5},
+		{`find([1, 2], fn(x) { x > 4 })`, object.NULL},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, true},
+		{`any([], fn(x) { true })`, false},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, true},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, false},
+		{`any([1, 2, "x"], fn(x) { x == 1 })`, true},
+		{`all([1, "x"], fn(x) { x > 1 })`, false},
+		{`flat_map([1, 2], fn(x) { [x, x * 10] })`, []int{1, 10, 2, 20}},
+		{`flat_map([1], fn(x) { x })`, &object.Error{Message: "function passed to `flat_map` must return ARRAY, got INTEGER"}},
+		{`map(zip([1, 2, 3], [10, 20]), fn(p) { p[0] + p[1] })`, []int{11, 22}},
+		{`len(zip([1], "ab", #{3, 4}))`, 1},
+		{`zip([1])`, &object.Error{Message: "wrong number of arguments. got=1, want at least 2"}},
+		{`zip([1], 2)`, &object.Error{Message: "second argument to `zip` must be iterable, got INTEGER"}},
+		{`map(1, fn(x) { x })`, &object.Error{Message: "first argument to `map` must be iterable, got INTEGER"}},
+		{`filter([1], 2)`, &object.Error{Message: "second argument to `filter` must be FUNCTION, got INTEGER"}},
+		{`map([1], fn(x, y) { x })`, &object.Error{Message: "wrong number of arguments: want=2, got=1"}},
+		{`map([1, 2], fn(x) { len(x) })`, &object.Error{Message: "argument to `len` not supported, got INTEGER"}},
+		{`let gen = fn() { yield 1; fn(x) { x }(1, 2); }; map(gen(), fn(x) { x })`, &object.Error{Message: "wrong number of arguments: want=1, got=2"}},
+		{`let r = map([1], fn(x) { x }); len(map(r, fn(x) { map(r, fn(y) { x + y }) }))`, 1},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestSorting(t *testing.T) {
+	tests := []vmTestCase{
+		{`sort([3, 1, 2])`, []int{1, 2, 3}},
+		{`sort([])`, []int{}},
+		{`sort([3, "b", 1.5, "a", 2, true]) == [true, 1.5, 2, 3, "a", "b"]`, true},
+		{`let n = if (false) { 1 }; sort([1, n, false])[0] == n`, true},
+		{`sort([[2], [1, 5], [1]]) == [[1], [1, 5], [2]]`, true},
+		{`sort("cab") == ["a", "b", "c"]`, true},
+		{`sort(#{3, 1, 2})`, []int{1, 2, 3}},
+		{`let a = [3, 1]; sort(a); a`, []int{3, 1}},
+		{`sort([1, 3, 2], fn(a, b) { b - a })`, []int{3, 2, 1}},
+		{`map(sort([[1, "a"], [0, "b"], [1, "c"]], fn(a, b) { a[0] - b[0] }), fn(p) { p[1] }) == ["b", "a", "c"]`, true},
+		{`sort([1, 2], fn(a, b) { a < b })`, &object.Error{Message: "comparator passed to `sort` must return INTEGER, got BOOLEAN"}},
+		{`sort([1, 2], fn(a) { a })`, &object.Error{Message: "wrong number of arguments: want=1, got=2"}},
+		{`sort(1)`, &object.Error{Message: "first argument to `sort` must be iterable, got INTEGER"}},
+		{`sort_by(["ccc", "a", "bb"], len) == ["a", "bb", "ccc"]`, true},
+		{`map(sort_by([[2, "a"], [1, "b"], [2, "c"], [1, "d"]], fn(p) { p[0] }), fn(p) { p[1] }) == ["b", "d", "a", "c"]`, true},
+		{`sort_by([{"age": 40}, {"age": 3}], fn(p) { p["age"] })[0]["age"]`, 3},
+		{`reverse([1, 2, 3])`, []int{3, 2, 1}},
+		{`reverse("abc")`, "cba"},
+		{`reverse("xe` + "\u0301" + `")`, "e\u0301x"},
+		{`reverse(1)`, &object.Error{Message: "argument to `reverse` must be ARRAY or STRING, got INTEGER"}},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestFileBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("s"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	host := &object.Host{FileRoots: []string{dir}}
+	prelude := fmt.Sprintf("let d = %q; let p = d + \"/f.txt\"; ", dir)
+
+	tests := []vmTestCase{
+		{`write_file(p, "hello"); read_file(p)`, "hello"},
+		{`write_file(p, "a"); append_file(p, b"b"); append_file(p, "c"); read_file(p)`, "abc"},
+		{`mkdir(d + "/sub/x"); write_file(d + "/sub/f", ""); list_dir(d + "/sub") == ["f", "x"]`, true},
+		{`write_file(p, ""); exists(p)`, true},
+		{`write_file(p, ""); remove(p); exists(p)`, false},
+		{`remove(d)`, &object.Error{Message: fmt.Sprintf("cannot remove %s: it is one of the allowed directories", dir)}},
+		{`mkdir(d + "/sub"); remove(d + "/sub/..")`, &object.Error{Message: fmt.Sprintf("cannot remove %s/sub/..: it is one of the allowed directories", dir)}},
+		{`exists(d + "/missing/file")`, false},
+		{`write_file(p, b"a\r\nb\n\nc"); map(file_lines(p), fn(l) { len(l) })`, []int{1, 1, 0, 1}},
+		{`write_file(p, b"x\n"); let n = 0; for (l in file_lines(p)) { n += 1; } n`, 1},
+		{`read_file(d + "/none")`, &object.Error{Message: fmt.Sprintf("open %s/none: no such file or directory", dir)}},
+		{`write_file(p, 1)`, &object.Error{Message: "second argument to `write_file` must be STRING or BYTES, got INTEGER"}},
+		{`read_file(d + "/../x")`, &object.Error{Message: fmt.Sprintf("access denied: %s/../x is outside the allowed directories", dir)}},
+		{`read_file(d + "/link/secret")`, &object.Error{Message: fmt.Sprintf("access denied: %s/link/secret is outside the allowed directories", dir)}},
+		{`write_file(d + "/link/new", "x")`, &object.Error{Message: fmt.Sprintf("access denied: %s/link/new is outside the allowed directories", dir)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := parse(prelude + tt.input)
+
+			env := object.NewEnvironment()
+			env.SetHost(host)
+			evaluated := evaluator.Eval(program, env)
+			testExpectedObject(t, tt.expected, evaluated)
+
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+			vm := New(comp.Bytecode())
+			vm.SetHost(host)
+			if err := vm.Run(); err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+			testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+		})
+	}
+
+	// Without roots of their own the engines use the default host, which
+	// allows no file access at all
+	runVmTests(t, []vmTestCase{
+		{prelude + `read_file(p)`, &object.Error{Message: "file access is not allowed"}},
+	})
+	evaluated := evaluator.Eval(parse(prelude+`exists(p)`), object.NewEnvironment())
+	testExpectedObject(t, &object.Error{Message: "file access is not allowed"}, evaluated)
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`min(3, 1, 2)`, 1},
+		{`max([3, 1, 2])`, 3},
+		{`max(1, 2.5)`, 2.5},
+		{`max(1.5, 2)`, 2},
+		{`type(min(1, 1.0))`, "INTEGER"},
+		{`max(99999999999999999998, 99999999999999999999) - 99999999999999999998`, 1},
+		{`let m = max(1, NAN, 3); m != m`, true},
+		{`min([])`, &object.Error{Message: "`min` of empty array"}},
+		{`sum([1, 2, 3])`, 6},
+		{`sum(1, 2.5)`, 3.5},
+		{`sum([])`, 0},
+		{`sum(9223372036854775807, 1) == 9223372036854775808`, true},
+		{`clamp(15, 0, 10)`, 10},
+		{`clamp(-1.5, 0, 10)`, 0},
+		{`clamp(0.5, 0, 10)`, 0.5},
+		{`clamp(5, 0, 10)`, 5},
+		{`clamp(5, 10, 0)`, &object.Error{Message: "lower bound of `clamp` is greater than upper bound"}},
+		{`pow(2, 10)`, 1024},
+		{`pow(2, 100) == 1267650600228229401496703205376`, true},
+		{`pow(2, -1)`, 0.5},
+		{`pow(4, 0.5)`, 2.0},
+		{`floor(2.7)`, 2},
+		{`floor(-2.5)`, -3},
+		{`ceil(2.1)`, 3},
+		{`trunc(-2.7)`, -2},
+		{`round(2.5)`, 3},
+		{`round(3.14159, 2)`, 3.14},
+		{`floor(7)`, 7},
+		{`floor(1e30) == 1000000000000000019884624838656`, true},
+		{`floor(NAN)`, &object.Error{Message: "cannot convert NaN to INTEGER"}},
+		{`floor("x")`, &object.Error{Message: "argument to `floor` must be INTEGER or FLOAT, got STRING"}},
+		{`log(E)`, 1.0},
+		{`log(8, 2)`, 3.0},
+		{`log(-1)`, &object.Error{Message: "log of negative number is not supported"}},
+		{`log2(1024)`, 10.0},
+		{`log10(1000)`, 3.0},
+		{`exp(0)`, 1.0},
+		{`sin(0)`, 0.0},
+		{`cos(PI)`, -1.0},
+		{`atan2(1, 1) * 4 == PI`, true},
+		{`INF > 1e308`, true},
+		{`NAN == NAN`, false},
+		{`let f = fn() { PI }; f() > 3`, true},
+		{`let PI = 3; PI`, 3},
+		{`seed(42); let a = [random(), random_int(1, 6), shuffle([1, 2, 3, 4]), choice([1, 2, 3])]; seed(42); a == [random(), random_int(1, 6), shuffle([1, 2, 3, 4]), choice([1, 2, 3])]`, true},
+		{`seed(1); all(map([1, 2, 3, 4, 5, 6, 7, 8], fn(x) { random_int(1, 3) }), fn(n) { n >= 1 && n <= 3 })`, true},
+		{`seed(1); let r = random(); r >= 0 && r < 1`, true},
+		{`sort(shuffle([3, 1, 2]))`, []int{1, 2, 3}},
+		{`random_int(2, 1)`, &object.Error{Message: "lower bound of `random_int` is greater than upper bound"}},
+		{`choice([])`, &object.Error{Message: "`choice` of empty array"}},
+	}
+
+	// seed() replaces the random source of the host, so give the test one
+	// of its own
+	defer object.SetHost(object.SetHost(&object.Host{}))
+
+	runConformanceTests(t, tests)
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`trim("  a b  ")`, "a b"},
+		{`trim("xxaxx", "x")`, "a"},
+		{`trim_left("  a  ")`, "a  "},
+		{`trim_right("  a  ")`, "  a"},
+		{`trim_right("a.,", ".,")`, "a"},
+		{`contains("monkey", "key")`, true},
+		{`starts_with("monkey", "mon")`, true},
+		{`ends_with("monkey", "mon")`, false},
+		{`index_of("日本語の本", "本")`, 1},
+		{`last_index_of("日本語の本", "本")`, 4},
+		{`index_of("abc", "x")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, &object.Error{Message: "negative count passed to `repeat`: -1"}},
+		{`repeat("ab", 9223372036854775807)`, &object.Error{Message: "result of `repeat` would be longer than 268435456 bytes"}},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`pad_right("a", 9223372036854775807)`, &object.Error{Message: "result of `pad_right` would be longer than 268435456 bytes"}},
+		{`pad_left("7", 3, "0")`, "007"},
+		{`pad_right("日本", 4)`, "日本  "},
+		{`pad_left("long", 2)`, "long"},
+		{`pad_left("a", 3, "ab")`, &object.Error{Message: "third argument to `pad_left` must be a single character, got ab"}},
+		{`replace_all("a.b.c", ".", "/")`, "a/b/c"},
+		{`ord("é")`, 233},
+		{`ord("ab")`, &object.Error{Message: "argument to `ord` must be a single character, got 2 characters"}},
+		{`chr(26412)`, "本"},
+		{`chr(-1)`, &object.Error{Message: "invalid code point: -1"}},
+		{`contains("a", 1)`, &object.Error{Message: "second argument to `contains` must be STRING, got INTEGER"}},
+		{`format("%-6s|%5.2f|%03d", "ab", 3.14159, 7)`, "ab    | 3.14|007"},
+		{`format("%s %v %s", [1, "x"], true, 2.0)`, "[1, x] true 2.0"},
+		{`format("%x %X %q %t %c %%", 255, "hi", "a", false, 26412)`, "ff 6869 \"a\" false 本 %"},
+		{`format("%d", 123456789012345678901234567890)`, "123456789012345678901234567890"},
+		{`format("%6.1e", 1234)`, "1.2e+03"},
+		{`format("%-4s|", "日本")`, "日本  |"},
+		{`format("%d", 1.5)`, &object.Error{Message: "format error: %d: expected INTEGER, got FLOAT"}},
+		{`format("%d %d", 1)`, &object.Error{Message: "format error: missing argument for %d"}},
+		{`format("%d", 1, 2)`, &object.Error{Message: "format error: too many arguments: format uses 1, got 2"}},
+		{`format("%y", 1)`, &object.Error{Message: "format error: %y: unknown verb %y"}},
+		{`format("50%")`, &object.Error{Message: "format error: incomplete directive \"%\" at end of format"}},
+		{`join(map([["a", 1], ["bcd", 22]], fn(r) { format("%-4s%3d", r[0], r[1]) }), "|")`, "a     1|bcd  22"},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`keys({"b": 1, "a": 2, 3: 3})`, "[b, a, 3]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`entries({"b": 1, [1, 2]: true})`, "[[b, 1], [[1, 2], true]]"},
+		{`keys({})`, "[]"},
+		{`fn() { let h = {"a": 1, "b": 2}; delete(h, "a"); keys(h) }()`, "[a, b]"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, "{a: 1, c: 3}"},
+		{`delete({"a": 1}, "x")`, "{a: 1}"},
+		{`keys(delete({"a": 1, "b": 2}, "a"))`, "[b]"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4}, {"d": 5})`, "{a: 1, b: 3, c: 4, d: 5}"},
+		{`merge({"a": {"x": 1}}, {"a": {"y": 2}})`, "{a: {y: 2}}"},
+		{`deep_merge({"a": {"x": 1, "z": 0}, "b": 1}, {"a": {"y": 2, "z": 3}, "b": {"c": 1}})`, "{a: {x: 1, z: 3, y: 2}, b: {c: 1}}"},
+		{`from_entries([["a", 1], ["b", 2], ["a", 3]])`, "{a: 3, b: 2}"},
+		{`from_entries(entries({"x": 1, "y": 2})) == {"x": 1, "y": 2}`, "true"},
+		{`from_entries(zip(["a", "b"], [1, 2]))`, "{a: 1, b: 2}"},
+		{`get({"a": 1}, "a", 0)`, "1"},
+		{`get({"a": 1}, "b", 0)`, "0"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({[1]: 2}, [1])`, "true"},
+		{`delete({[1]: 2}, [1])`, "{}"},
+	}
+
+	for i, tt := range tests {
+		tests[i].input = fmt.Sprintf("format(\"%%v\", %s)", tt.input)
+	}
+	runConformanceTests(t, tests)
+}
+
+func TestHashBuiltinErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`keys([1])`, &object.Error{Message: "argument to `keys` must be HASH, got ARRAY"}},
+		{`merge({}, 1)`, &object.Error{Message: "second argument to `merge` must be HASH, got INTEGER"}},
+		{`merge()`, &object.Error{Message: "wrong number of arguments. got=0, want at least 1"}},
+		{`delete({}, [1, {}])`, &object.Error{Message: "unusable as hash key: ARRAY"}},
+		{`has({}, [1, {}])`, &object.Error{Message: "unusable as hash key: ARRAY"}},
+		{`has({"a": 1}, [fn() {}])`, &object.Error{Message: "unusable as hash key: ARRAY"}},
+		{`delete({"a": 1}, #{1})`, &object.Error{Message: "unusable as hash key: SET"}},
+		{`get({}, {})`, &object.Error{Message: "unusable as hash key: HASH"}},
+		{`get({})`, &object.Error{Message: "wrong number of arguments. got=1, want=2 or 3"}},
+		{`from_entries([["a", 1], ["b"]])`, &object.Error{Message: "entries passed to `from_entries` must be [key, value] arrays, got [b]"}},
+		{`from_entries(1)`, &object.Error{Message: "argument to `from_entries` must be iterable, got INTEGER"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`type(1)`, "INTEGER"},
+		{`type(10000000000 * 10000000000)`, "INTEGER"},
+		{`type(1.5)`, "FLOAT"},
+		{`type("a")`, "STRING"},
+		{`type(if (false) { 1 })`, "NULL"},
+		{`type({})`, "HASH"},
+		{`type(fn(x) { x })`, "FUNCTION"},
+		{`let y = 1; type(fn(x) { x + y })`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`struct Point { x, y } type(Point(1, 2))`, "Point"},
+		{`str([is_int(1), is_int(1.0), is_float(1.0), is_number(1), is_number("1")])`, "[true, false, true, true, false]"},
+		{`str([is_string("a"), is_bool(false), is_null(if (false) { 1 }), is_null(0)])`, "[true, true, true, false]"},
+		{`str([is_array([]), is_hash({}), is_set(set()), is_bytes(b"a"), is_array({})])`, "[true, true, true, true, false]"},
+		{`str([is_function(fn() {}), is_function(len), is_function("len")])`, "[true, true, false]"},
+		{`int("42")`, 42},
+		{`int("-17")`, -17},
+		{`int("ff", 16)`, 255},
+		{`int("101", 2)`, 5},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int("123456789012345678901234567890") == 123456789012345678901234567890`, true},
+		{`int("5") + 1`, 6},
+		{`int(" 5")`, &object.Error{Message: `cannot parse " 5" as INTEGER`}},
+		{`int("5.0")`, &object.Error{Message: `cannot parse "5.0" as INTEGER`}},
+		{`int("")`, &object.Error{Message: `cannot parse "" as INTEGER`}},
+		{`int("12", 37)`, &object.Error{Message: "invalid base: 37"}},
+		{`int(1, 16)`, &object.Error{Message: "first argument to `int` must be STRING when a base is given, got INTEGER"}},
+		{`int(NAN)`, &object.Error{Message: "cannot convert NaN to INTEGER"}},
+		{`int(true)`, &object.Error{Message: "cannot convert BOOLEAN to INTEGER"}},
+		{`float("2.5")`, 2.5},
+		{`float("1e3")`, 1000.0},
+		{`float(2)`, 2.0},
+		{`float("abc")`, &object.Error{Message: `cannot parse "abc" as FLOAT`}},
+		{`float("0x10")`, &object.Error{Message: `cannot parse "0x10" as FLOAT`}},
+		{`float("1_000.5")`, &object.Error{Message: `cannot parse "1_000.5" as FLOAT`}},
+		{`float("infinity")`, &object.Error{Message: `cannot parse "infinity" as FLOAT`}},
+		{`float("nan")`, &object.Error{Message: `cannot parse "nan" as FLOAT`}},
+		{`float("-Inf") < 0 && float(str(float("Inf"))) > 1e308`, true},
+		{`let n = float("NaN"); n != n`, true},
+		{`float([])`, &object.Error{Message: "cannot convert ARRAY to FLOAT"}},
+		{`str(5) + "1"`, "51"},
+		{`str(2.0)`, "2.0"},
+		{`str("a")`, "a"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`bool("true")`, true},
+		{`bool("false")`, false},
+		{`bool(0)`, true},
+		{`bool(if (false) { 1 })`, false},
+		{`bool("yes")`, &object.Error{Message: `cannot parse "yes" as BOOLEAN`}},
+		{`repr("a")`, `"a"`},
+		{`repr(1)`, "1"},
+		{`repr([1, "1", {"k": ["v"]}])`, `[1, "1", {"k": ["v"]}]`},
+		{`struct P { name } repr(P("x"))`, `P{name: "x"}`},
+		{`type()`, &object.Error{Message: "wrong number of arguments. got=0, want=1"}},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestStdinBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		stdin    string
+		expected interface{}
+	}{
+		{`repr([read_line(), read_line(), read_line()])`, "a\nb\r\nc", `["a", "b", "c"]`},
+		{`let a = read_line(); repr([a, read_line()])`, "a\n", `["a", null]`},
+		{`repr(read_line())`, "", "null"},
+		{`read_all()`, "x\ny\n", "x\ny\n"},
+		{`let h = read_line(); repr([h, read_all(), read_all()])`, "h\nrest\n", `["h", "rest\n", null]`},
+		{`repr(read_all())`, "", "null"},
+		{`repr(map(lines(), fn(l) { upper(l) }))`, "one\ntwo\n\nthree", `["ONE", "TWO", "", "THREE"]`},
+		{`let h = read_line(); repr([h, map(lines(), len)])`, "head\nab\nabc\n", `["head", [2, 3]]`},
+		{`repr(map(lines(), len))`, "", "[]"},
+		{`read_line(1)`, "", &object.Error{Message: "wrong number of arguments. got=1, want=0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := parse(tt.input)
+
+			env := object.NewEnvironment()
+			env.SetHost(&object.Host{Stdin: strings.NewReader(tt.stdin)})
+			evaluated := evaluator.Eval(program, env)
+			testExpectedObject(t, tt.expected, evaluated)
+
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+			vm := New(comp.Bytecode())
+			vm.SetHost(&object.Host{Stdin: strings.NewReader(tt.stdin)})
+			if err := vm.Run(); err != nil {
+				t.Fatalf("vm error: %s", err)
+			}
+			testExpectedObject(t, tt.expected, vm.LastPoppedStackElem())
+		})
+	}
+}
+
+func TestProcessBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`repr(args())`, `["-v", "in.txt"]`},
+		{`env("HOME")`, "/home/monkey"},
+		{`repr(env("MISSING"))`, "null"},
+		{`repr(env_all())`, `{"HOME": "/home/monkey", "LANG": "C"}`},
+		{`eputs("oops", 1); 2`, 2},
+		{`env(1)`, &object.Error{Message: "argument to `env` must be STRING, got INTEGER"}},
+		{`exit("1")`, &object.Error{Message: "argument to `exit` must be INTEGER, got STRING"}},
+	}
+
+	var stderr strings.Builder
+	runHostConformanceTests(t, tests, &object.Host{
+		Args:   []string{"-v", "in.txt"},
+		Env:    map[string]string{"LANG": "C", "HOME": "/home/monkey"},
+		Stderr: &stderr,
+	})
+
+	// Both engines ran the eputs test
+	if stderr.String() != "oops\n1\noops\n1\n" {
+		t.Errorf("wrong stderr output: %q", stderr.String())
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input string
+		code  int
+	}{
+		{`exit(); puts("unreachable")`, 0},
+		{`let f = fn() { exit(3); 1 }; f(); 99`, 3},
+		{`map([1, 2, 3], fn(x) { if (x == 2) { exit(x) } x })`, 2},
+		{`let g = fn() { yield 1; exit(4); yield 2 }; for (x in g()) { x }`, 4},
+		{`sort([3, 1, 2], fn(a, b) { exit(5) })`, 5},
+		{`let g = fn() { yield 1; exit(6) }; map(g(), fn(x) { x })`, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := parse(tt.input)
+
+			evaluated := evaluator.Eval(program, object.NewEnvironment())
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Exit == nil {
+				t.Fatalf("evaluator did not exit. got=%T (%+v)", evaluated, evaluated)
+			}
+			if errObj.Exit.Code != tt.code {
+				t.Errorf("evaluator exited with wrong code. want=%d, got=%d", tt.code, errObj.Exit.Code)
+			}
+
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+			vm := New(comp.Bytecode())
+			var exit *object.ExitError
+			if err := vm.Run(); !errors.As(err, &exit) {
+				t.Fatalf("vm did not exit. got=%v", err)
+			}
+			if exit.Code != tt.code {
+				t.Errorf("vm exited with wrong code. want=%d, got=%d", tt.code, exit.Code)
+			}
+		})
+	}
+}
+
+func TestCSVBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{`repr(csv_parse("a,b
+1,2.5
+x,true"))`, `[["a", "b"], [1, 2.5], ["x", true]]`},
+		{`repr(csv_parse("007,+1,-3,1e3,,false,123456789012345678901234567890"))`, `[["007", "+1", -3, "1e3", null, false, 123456789012345678901234567890]]`},
+		{`repr(csv_parse("0.10,1e5,-0,1e400,1.5,-2.25,1e-9,0"))`, `[["0.10", "1e5", "-0", "1e400", 1.5, -2.25, 1e-9, 0]]`},
+		{`repr(csv_parse("1,,true", {"infer": false}))`, `[["1", "", "true"]]`},
+		{`repr(csv_parse(decode(b"name,quote\nann,\"says \"\"hi\"\", then, leaves\"\nbob,\"two\nlines\"")))`, `[["name", "quote"], ["ann", "says \"hi\", then, leaves"], ["bob", "two\nlines"]]`},
+		{`repr(csv_parse("name;age
+ann;31
+bob;", {"delimiter": ";", "header": true}))`, `[{"name": "ann", "age": 31}, {"name": "bob", "age": null}]`},
+		{`str(keys(csv_parse("z,a,m
+1,2,3", {"header": true})[0]))`, "[z, a, m]"},
+		{`repr(csv_parse("a,b", {"header": true}))`, `[]`},
+		{`repr(csv_parse(""))`, `[]`},
+		{`repr(csv_parse(decode(b"a,b\"c\""), {"lazy_quotes": true}))`, `[["a", "b\"c\""]]`},
+		{`csv_stringify([["a", "b"], [1, 2.5], [true, if (false) { 1 }]])`, "a,b\n1,2.5\ntrue,\n"},
+		{`csv_stringify([["has,comma", decode(b"has \"quote\"")]])`, "\"has,comma\",\"has \"\"quote\"\"\"\n"},
+		{`csv_stringify([{"name": "ann", "age": 31}, {"name": "bob", "city": "Oslo"}])`, "name,age,city\nann,31,\nbob,,Oslo\n"},
+		{`csv_stringify([{"a": 1}], {"header": false, "delimiter": "|"})`, "1\n"},
+		{`csv_stringify([["a", "b"]], {"delimiter": decode(b"\t")})`, "a\tb\n"},
+		{`csv_stringify([])`, ""},
+		{`let rows = [{"id": 1, "tags": "x;y"}, {"id": 2, "tags": ""}]; csv_parse(csv_stringify(rows), {"header": true}) == [{"id": 1, "tags": "x;y"}, {"id": 2, "tags": if (false) { 1 }}]`, true},
+		{`repr(csv_parse("a,b
+1"))`, `[["a", "b"], [1]]`},
+		{`csv_parse("a,b
+1", {"header": true})`, &object.Error{Message: "invalid CSV: record on line 2: wrong number of fields"}},
+		{`csv_parse(decode(b"a,\"b"))`, &object.Error{Message: "invalid CSV: parse error on line 1, column 5: extraneous or missing \" in quoted-field"}},
+		{`csv_parse("a,a", {"header": true})`, &object.Error{Message: "invalid CSV: duplicate column \"a\" in header"}},
+		{`csv_parse("a", {"delimiter": ";;"})`, &object.Error{Message: "option `delimiter` of `csv_parse` must be a single character, got \";;\""}},
+		{`csv_parse("a", {"header": "yes"})`, &object.Error{Message: "option `header` of `csv_parse` must be BOOLEAN, got STRING"}},
+		{`csv_parse("a", {"separator": ","})`, &object.Error{Message: "unknown option for `csv_parse`: \"separator\""}},
+		{`csv_stringify([[1]], {"infer": true})`, &object.Error{Message: "unknown option for `csv_stringify`: \"infer\""}},
+		{`csv_stringify([[[1]]])`, &object.Error{Message: "CSV stringify error: cannot write ARRAY as a CSV field"}},
+		{`csv_stringify([[1], {"a": 1}])`, &object.Error{Message: "CSV stringify error: rows must all be ARRAY or all be HASH, got HASH"}},
+		{`csv_stringify("a,b")`, &object.Error{Message: "first argument to `csv_stringify` must be ARRAY, got STRING"}},
+	}
+
+	runConformanceTests(t, tests)
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []vmTestCase{
+		{`let out = ""; for (k in {"b": 1, "a": 2, "c": 3}) { out += k; } out`, "bac"},
+		{`let out = ""; for (k in {"x": 1, "y": 2, "x": 3}) { out += k; } out`, "xy"},
+		{`let h = {"b": 1, "a": 2}; let sum = 0; for (k in h) { sum += h[k]; } sum`, 3},
+	}
+
+	runConformanceTests(t, tests)
+
+	runVmTests(t, []vmTestCase{
+		{`json_stringify({"z": 1, "a": [2, 3], "m": {"k": 4, "b": 5}})`, `{"z":1,"a":[2,3],"m":{"k":4,"b":5}}`},
+	})
+}